@@ -0,0 +1,107 @@
+// Command lambda runs the API as a native AWS Lambda function behind an API
+// Gateway REST API (payload format 1.0), an HTTP API (payload format 2.0) or
+// a Lambda Function URL. It serves the same routes as cmd/server.
+//
+// Recorded events can be replayed locally without the Lambda runtime by
+// passing them with -event before any config flags. Events run in order in
+// one process and each response is printed to stdout:
+//
+//	STORAGE_BACKEND=memory go run ./cmd/lambda \
+//		-event cmd/lambda/testdata/apigw-v2-create-todo.json \
+//		-event cmd/lambda/testdata/apigw-v1-list-todos.json
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"echo-todo/internal/app"
+	"echo-todo/internal/config"
+	"echo-todo/internal/lambdaproxy"
+	"echo-todo/internal/logging"
+)
+
+func main() {
+	eventFiles, args := splitEventFlags(os.Args[1:])
+
+	cfg, err := config.LoadFrom(args, os.LookupEnv)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	logger, err := logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	a, err := app.New(cfg, logger)
+	if err != nil {
+		logger.Error("failed to initialize application", "error", err)
+		os.Exit(1)
+	}
+	handler := lambdaproxy.NewHandler(a.Echo)
+
+	closeApp := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := a.Close(ctx); err != nil {
+			logger.Error("failed to release resources", "error", err)
+		}
+	}
+
+	if len(eventFiles) > 0 {
+		err := invokeLocal(handler, eventFiles)
+		closeApp()
+		if err != nil {
+			logger.Error("local invocation failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Lambda sends SIGTERM before shutting the execution environment down
+	lambda.StartWithOptions(handler.Invoke, lambda.WithEnableSIGTERM(closeApp))
+}
+
+// splitEventFlags removes the leading -event flags from args and returns
+// their values and the remaining arguments
+func splitEventFlags(args []string) ([]string, []string) {
+	var files []string
+	for len(args) >= 2 && (args[0] == "-event" || args[0] == "--event") {
+		files = append(files, args[1])
+		args = args[2:]
+	}
+	return files, args
+}
+
+// invokeLocal feeds each event file through handler and prints the responses
+func invokeLocal(handler *lambdaproxy.Handler, files []string) error {
+	for _, file := range files {
+		event, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		// Same as the default function timeout in .env.example
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		res, err := handler.Invoke(ctx, event)
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		out, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return err
+		}
+		os.Stdout.Write(append(out, '\n'))
+	}
+	return nil
+}
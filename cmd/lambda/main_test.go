@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"echo-todo/internal/app"
+	"echo-todo/internal/config"
+	"echo-todo/internal/lambdaproxy"
+)
+
+// newTestHandler serves the shared router over the memory backend, which
+// authenticates the dev-token used by the fixtures in development
+func newTestHandler(t *testing.T) *lambdaproxy.Handler {
+	t.Helper()
+	env := map[string]string{"STORAGE_BACKEND": "memory", "ENVIRONMENT": "development"}
+	cfg, err := config.LoadFrom(nil, func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	})
+	if err != nil {
+		t.Fatalf("config.LoadFrom() error = %v", err)
+	}
+	a, err := app.New(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("app.New() error = %v", err)
+	}
+	t.Cleanup(func() { a.Close(context.Background()) })
+	return lambdaproxy.NewHandler(a.Echo)
+}
+
+// invokeFixture feeds a file of testdata through handler
+func invokeFixture(t *testing.T, handler *lambdaproxy.Handler, name string) interface{} {
+	t.Helper()
+	event, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := handler.Invoke(context.Background(), event)
+	if err != nil {
+		t.Fatalf("Invoke(%s) error = %v", name, err)
+	}
+	return res
+}
+
+type todoResponse struct {
+	Success bool `json:"success"`
+	Data    struct {
+		ID          string `json:"id"`
+		OwnerID     string `json:"owner_id"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Version     int    `json:"version"`
+	} `json:"data"`
+}
+
+type todoListResponse struct {
+	Success bool `json:"success"`
+	Data    []struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	} `json:"data"`
+}
+
+func decodeBody(t *testing.T, body string, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(body), v); err != nil {
+		t.Fatalf("body %q is not JSON: %v", body, err)
+	}
+}
+
+// TestFixtures replays the recorded events in the order of the doc comment
+// of main: todos created through an HTTP API and a Function URL are listed
+// through a REST API
+func TestFixtures(t *testing.T) {
+	handler := newTestHandler(t)
+
+	// HTTP API, payload format 2.0 on a named stage
+	v2, ok := invokeFixture(t, handler, "apigw-v2-create-todo.json").(*events.APIGatewayV2HTTPResponse)
+	if !ok {
+		t.Fatal("apigw-v2-create-todo.json: want an APIGatewayV2HTTPResponse")
+	}
+	if v2.StatusCode != http.StatusCreated {
+		t.Fatalf("apigw-v2-create-todo.json: status = %d, body %s", v2.StatusCode, v2.Body)
+	}
+	for key, want := range map[string]string{
+		"Content-Type": "application/json",
+		"Etag":         `"1"`,
+		"X-Request-Id": "JKJaXmPLvHcESHA=",
+	} {
+		if got := v2.Headers[key]; got != want {
+			t.Errorf("apigw-v2-create-todo.json: header %s = %q, want %q", key, got, want)
+		}
+	}
+	if v2.IsBase64Encoded {
+		t.Error("apigw-v2-create-todo.json: JSON body is base64 encoded")
+	}
+	var milk todoResponse
+	decodeBody(t, v2.Body, &milk)
+	if !milk.Success || milk.Data.ID == "" || milk.Data.OwnerID != "dev" ||
+		milk.Data.Title != "Buy milk" || milk.Data.Description != "From the Lambda fixture" || milk.Data.Version != 1 {
+		t.Errorf("apigw-v2-create-todo.json: body = %s", v2.Body)
+	}
+
+	// Function URL with a base64 encoded body and a client request id
+	fu, ok := invokeFixture(t, handler, "function-url-create-todo.json").(*events.LambdaFunctionURLResponse)
+	if !ok {
+		t.Fatal("function-url-create-todo.json: want a LambdaFunctionURLResponse")
+	}
+	if fu.StatusCode != http.StatusCreated {
+		t.Fatalf("function-url-create-todo.json: status = %d, body %s", fu.StatusCode, fu.Body)
+	}
+	if got := fu.Headers["X-Request-Id"]; got != "fixture-function-url-1" {
+		t.Errorf("function-url-create-todo.json: X-Request-Id = %q, want the one sent by the client", got)
+	}
+	if got := fu.Headers["Content-Type"]; got != "application/json" {
+		t.Errorf("function-url-create-todo.json: Content-Type = %q", got)
+	}
+	var plants todoResponse
+	decodeBody(t, fu.Body, &plants)
+	if !plants.Success || plants.Data.Title != "Water the plants" || plants.Data.OwnerID != "dev" {
+		t.Errorf("function-url-create-todo.json: body = %s", fu.Body)
+	}
+
+	// REST API, payload format 1.0, newest first
+	v1, ok := invokeFixture(t, handler, "apigw-v1-list-todos.json").(*events.APIGatewayProxyResponse)
+	if !ok {
+		t.Fatal("apigw-v1-list-todos.json: want an APIGatewayProxyResponse")
+	}
+	if v1.StatusCode != http.StatusOK {
+		t.Fatalf("apigw-v1-list-todos.json: status = %d, body %s", v1.StatusCode, v1.Body)
+	}
+	header := http.Header(v1.MultiValueHeaders)
+	if got := header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("apigw-v1-list-todos.json: Content-Type = %q", got)
+	}
+	if got := header.Get("X-Request-Id"); got != "c6af9ac6-7b61-11e6-9a41-93e8deadbeef" {
+		t.Errorf("apigw-v1-list-todos.json: X-Request-Id = %q, want the Lambda request id", got)
+	}
+	var list todoListResponse
+	decodeBody(t, v1.Body, &list)
+	if !list.Success || len(list.Data) != 2 ||
+		list.Data[0].ID != plants.Data.ID || list.Data[1].ID != milk.Data.ID {
+		t.Errorf("apigw-v1-list-todos.json: body = %s, want the two todos newest first", v1.Body)
+	}
+
+	// Function URL without credentials on a public route
+	ready, ok := invokeFixture(t, handler, "function-url-readyz.json").(*events.LambdaFunctionURLResponse)
+	if !ok {
+		t.Fatal("function-url-readyz.json: want a LambdaFunctionURLResponse")
+	}
+	if ready.StatusCode != http.StatusOK {
+		t.Fatalf("function-url-readyz.json: status = %d, body %s", ready.StatusCode, ready.Body)
+	}
+	if got := ready.Headers["X-Request-Id"]; got != "7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d" {
+		t.Errorf("function-url-readyz.json: X-Request-Id = %q, want the Lambda request id", got)
+	}
+	var health struct {
+		Status string `json:"status"`
+	}
+	decodeBody(t, ready.Body, &health)
+	if health.Status != "pass" {
+		t.Errorf("function-url-readyz.json: body = %s", ready.Body)
+	}
+}
+
+func TestInvokeUnsupportedEvent(t *testing.T) {
+	handler := newTestHandler(t)
+	_, err := handler.Invoke(context.Background(), json.RawMessage(`{"Records":[]}`))
+	if err != lambdaproxy.ErrUnsupportedEvent {
+		t.Errorf("Invoke() error = %v, want ErrUnsupportedEvent", err)
+	}
+}
@@ -1,6 +1,6 @@
 // @title Echo TODO API
 // @version 1.0
-// @description A simple TODO API built with Echo framework and DynamoDB
+// @description A simple TODO API built with Echo framework and DynamoDB. Errors are returned as RFC 9457 problem details (utils.Problem) when the request sends Accept: application/problem+json, see docs/PROBLEMS.md.
 // @termsOfService http://swagger.io/terms/
 
 // @contact.name API Support
@@ -13,66 +13,91 @@
 // @BasePath /
 // @schemes http https
 
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Bearer token, e.g. "Bearer my-token"
+
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key created through /api/v1/api-keys, limited to its scopes
+
 package main
 
 import (
+	"context"
 	"log"
-	"net/http"
-
-	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
-	echoSwagger "github.com/swaggo/echo-swagger"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"echo-todo/internal/app"
 	"echo-todo/internal/config"
-	"echo-todo/internal/handlers"
-	"echo-todo/internal/repository"
-	"echo-todo/internal/services"
-	_ "echo-todo/docs"
+	"echo-todo/internal/logging"
 )
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
 
-	todoRepo, err := repository.NewDynamoDBTodoRepository(cfg.TableName)
+	logger, err := logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
 	if err != nil {
-		log.Fatalf("Failed to initialize todo repository: %v", err)
+		log.Fatalf("Failed to initialize logger: %v", err)
 	}
+	slog.SetDefault(logger)
 
-	// Initialize service layer
-	todoService := services.NewTodoService(todoRepo)
-	
-	// Initialize handler layer
-	todoHandler := handlers.NewTodoHandler(todoService)
-
-	e := echo.New()
-	e.Use(middleware.Logger())
-	e.Use(middleware.Recover())
-	e.Use(middleware.CORS())
-
-	e.GET("/", func(c echo.Context) error {
-		return c.JSON(http.StatusOK, map[string]string{
-			"message": "Echo TODO API on AWS Lambda with LWA",
-			"status":  "ready",
-		})
-	})
-
-	e.GET("/health", func(c echo.Context) error {
-		return c.JSON(http.StatusOK, map[string]string{"status": "healthy"})
-	})
-
-	// Swagger endpoint
-	e.GET("/swagger/*", echoSwagger.WrapHandler)
-
-	// API routes
-	api := e.Group("/api/v1")
-	
-	// TODO routes
-	todos := api.Group("/todos")
-	todos.POST("", todoHandler.CreateTodo)
-	todos.GET("", todoHandler.GetAllTodos)
-	todos.GET("/:id", todoHandler.GetTodo)
-	todos.PUT("/:id", todoHandler.UpdateTodo)
-	todos.DELETE("/:id", todoHandler.DeleteTodo)
-
-	e.Logger.Fatal(e.Start(":" + cfg.Port))
-}
\ No newline at end of file
+	a, err := app.New(cfg, logger)
+	if err != nil {
+		logger.Error("failed to initialize application", "error", err)
+		os.Exit(1)
+	}
+
+	e := a.Echo
+	e.Server.ReadTimeout = cfg.ServerReadTimeout
+	e.Server.WriteTimeout = cfg.ServerWriteTimeout
+	e.Server.IdleTimeout = cfg.ServerIdleTimeout
+	e.Server.MaxHeaderBytes = cfg.ServerMaxHeaderBytes
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("starting server", "port", cfg.Port, "storage_backend", cfg.StorageBackend, "environment", cfg.Environment)
+		serverErr <- e.Start(":" + cfg.Port)
+	}()
+
+	exitCode := 0
+	select {
+	case err := <-serverErr:
+		logger.Error("server stopped", "error", err)
+		exitCode = 1
+	case <-ctx.Done():
+		// Restore the default signal handling so a second signal kills the process
+		stop()
+		logger.Info("shutting down, waiting for in-flight requests", "timeout", cfg.ShutdownTimeout)
+		if err := withTimeout(cfg.ShutdownTimeout, e.Shutdown); err != nil {
+			logger.Error("in-flight requests did not finish before the shutdown timeout", "error", err)
+			exitCode = 1
+		}
+	}
+
+	if err := withTimeout(cfg.ShutdownTimeout, a.Close); err != nil {
+		logger.Error("failed to release resources", "error", err)
+		exitCode = 1
+	}
+	logger.Info("shutdown complete")
+	os.Exit(exitCode)
+}
+
+// withTimeout calls fn with a context cancelled after timeout
+func withTimeout(timeout time.Duration, fn func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return fn(ctx)
+}
@@ -0,0 +1,280 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	echoSwagger "github.com/swaggo/echo-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+
+	_ "echo-todo/docs"
+	"echo-todo/internal/auth"
+	"echo-todo/internal/config"
+	"echo-todo/internal/handlers"
+	"echo-todo/internal/health"
+	"echo-todo/internal/metrics"
+	appmiddleware "echo-todo/internal/middleware"
+	"echo-todo/internal/repository"
+	"echo-todo/internal/services"
+	"echo-todo/internal/tracing"
+	"echo-todo/pkg/models"
+	"echo-todo/pkg/utils"
+)
+
+// App is the Echo application with its storage, services and routes, shared
+// by the HTTP server and the Lambda entrypoints
+type App struct {
+	Echo *echo.Echo
+
+	// storageCloser releases the connection pool of SQL backends, nil otherwise
+	storageCloser   io.Closer
+	shutdownTracing func(context.Context) error
+}
+
+// handlerSet holds what registerRoutes mounts
+type handlerSet struct {
+	todo           *handlers.TodoHandler
+	apiKey         *handlers.APIKeyHandler
+	health         *handlers.HealthHandler
+	metrics        http.Handler
+	authenticators []appmiddleware.Authenticator
+}
+
+// New builds the application for cfg. Close releases its resources.
+func New(cfg *config.Config, logger *slog.Logger) (*App, error) {
+	registry := metrics.NewRegistry()
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:     cfg.TracingExporter,
+		OTLPEndpoint: cfg.TracingOTLPEndpoint,
+		SampleRatio:  cfg.TracingSampleRatio,
+		Environment:  cfg.Environment,
+	}, os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	a := &App{shutdownTracing: shutdownTracing}
+
+	todoRepo, apiKeyRepo, err := newRepositories(cfg, logger)
+	if err != nil {
+		a.Close(context.Background())
+		return nil, err
+	}
+	a.storageCloser, _ = todoRepo.(io.Closer)
+	checker := newReadinessChecker(cfg, todoRepo, apiKeyRepo)
+	todoRepo = repository.NewMetricsTodoRepository(todoRepo, cfg.StorageBackend, registry)
+
+	// Initialize service layer
+	todoService := services.NewTracingTodoService(services.NewTodoService(todoRepo, logger))
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, logger)
+
+	authenticators, err := newAuthenticators(cfg, apiKeyService)
+	if err != nil {
+		a.Close(context.Background())
+		return nil, err
+	}
+	if cfg.DevAuth() {
+		logger.Warn("no authentication configured, requests are authenticated as user dev with the development bearer token",
+			"token", auth.DevUserToken)
+	}
+
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.HTTPErrorHandler = appmiddleware.ErrorHandler(logger)
+	e.Binder = &utils.JSONBinder{DisallowUnknownFields: cfg.RequestDisallowUnknownFields}
+	utils.SetTodoTextLimits(cfg.TodoTitleMaxLength, cfg.TodoDescriptionMaxLength)
+	utils.SetMaxRequestBodyBytes(int64(cfg.RequestMaxBodyBytes))
+	e.Use(appmiddleware.RequestID())
+	e.Use(otelecho.Middleware(tracing.ServiceName, otelecho.WithSkipper(func(c echo.Context) bool {
+		// Keep scrapes and probes out of the traces
+		switch c.Path() {
+		case "/metrics", "/health", "/livez", "/readyz":
+			return true
+		}
+		return false
+	})))
+	e.Use(appmiddleware.RequestLogger(logger))
+	e.Use(appmiddleware.Metrics(registry))
+	e.Use(middleware.Recover())
+	e.Use(middleware.CORS())
+
+	registerRoutes(e, logger, handlerSet{
+		todo:           handlers.NewTodoHandler(todoService, logger),
+		apiKey:         handlers.NewAPIKeyHandler(apiKeyService, logger),
+		health:         handlers.NewHealthHandler(checker, logger),
+		metrics:        metrics.Handler(registry),
+		authenticators: authenticators,
+	})
+	a.Echo = e
+
+	return a, nil
+}
+
+// Close releases the storage connections and flushes pending spans
+func (a *App) Close(ctx context.Context) error {
+	var errs []error
+	if a.storageCloser != nil {
+		if err := a.storageCloser.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := a.shutdownTracing(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// registerRoutes mounts every route of the API on e
+func registerRoutes(e *echo.Echo, logger *slog.Logger, h handlerSet) {
+	e.GET("/", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{
+			"message": "Echo TODO API on AWS Lambda with LWA",
+			"status":  "ready",
+		})
+	})
+
+	// Probes. /health is kept as a liveness alias for existing checks.
+	e.GET("/livez", h.health.Livez)
+	e.GET("/readyz", h.health.Readyz)
+	e.GET("/health", h.health.Livez)
+
+	// Prometheus metrics
+	e.GET("/metrics", echo.WrapHandler(h.metrics))
+
+	// Swagger endpoint
+	e.GET("/swagger/*", echoSwagger.WrapHandler)
+
+	// API routes
+	api := e.Group("/api/v1", appmiddleware.Authenticate(logger, h.authenticators...))
+
+	// TODO routes
+	canRead := appmiddleware.RequireScope(models.ScopeTodosRead)
+	canWrite := appmiddleware.RequireScope(models.ScopeTodosWrite)
+	todos := api.Group("/todos")
+	todos.POST("", h.todo.CreateTodo, canWrite)
+	todos.GET("", h.todo.GetAllTodos, canRead)
+	todos.GET("/search", h.todo.SearchTodos, canRead)
+	todos.GET("/:id", h.todo.GetTodo, canRead)
+	todos.PUT("/:id", h.todo.UpdateTodo, canWrite)
+	todos.PATCH("/:id", h.todo.PatchTodo, canWrite)
+	todos.DELETE("/:id", h.todo.DeleteTodo, canWrite)
+
+	// API key routes
+	apiKeys := api.Group("/api-keys", appmiddleware.RequireScope(models.ScopeAPIKeysManage))
+	apiKeys.POST("", h.apiKey.CreateAPIKey)
+	apiKeys.GET("", h.apiKey.ListAPIKeys)
+	apiKeys.POST("/:id/rotate", h.apiKey.RotateAPIKey)
+	apiKeys.DELETE("/:id", h.apiKey.RevokeAPIKey)
+}
+
+// newRepositories creates the repositories for the configured storage backend
+func newRepositories(cfg *config.Config, logger *slog.Logger) (repository.TodoRepository, repository.APIKeyRepository, error) {
+	switch cfg.StorageBackend {
+	case config.StorageSQLite:
+		todoRepo, err := repository.NewSQLiteTodoRepository(context.Background(), cfg.SQLitePath, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		return todoRepo, todoRepo.APIKeys(), nil
+	case config.StoragePostgres:
+		todoRepo, err := repository.NewPostgresTodoRepository(context.Background(), cfg.PostgresDSN, repository.PostgresPoolOptions{
+			MaxOpenConns:    cfg.PostgresMaxOpenConns,
+			MaxIdleConns:    cfg.PostgresMaxIdleConns,
+			ConnMaxLifetime: cfg.PostgresConnMaxLifetime,
+			ConnMaxIdleTime: cfg.PostgresConnMaxIdleTime,
+		}, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		return todoRepo, todoRepo.APIKeys(), nil
+	case config.StorageMemory:
+		return repository.NewMemoryTodoRepository(), repository.NewMemoryAPIKeyRepository(), nil
+	default:
+		todoRepo, err := repository.NewDynamoDBTodoRepository(
+			cfg.TableName,
+			logger,
+			awsconfig.WithRegion(cfg.AWSRegion),
+			awsconfig.WithBaseEndpoint(cfg.AWSEndpointURL),
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+		apiKeyRepo, err := repository.NewDynamoDBAPIKeyRepository(
+			cfg.APIKeysTable,
+			logger,
+			awsconfig.WithRegion(cfg.AWSRegion),
+			awsconfig.WithBaseEndpoint(cfg.AWSEndpointURL),
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+		return todoRepo, apiKeyRepo, nil
+	}
+}
+
+// newReadinessChecker registers the checks deciding whether the server can
+// serve traffic
+func newReadinessChecker(cfg *config.Config, todoRepo repository.TodoRepository, apiKeyRepo repository.APIKeyRepository) *health.Checker {
+	checker := health.NewChecker(cfg.HealthCheckTimeout, cfg.HealthCacheTTL)
+	checker.Add(health.Check{
+		Name: "config",
+		Run:  func(context.Context) error { return cfg.Validate() },
+	})
+	if pinger, ok := todoRepo.(repository.Pinger); ok {
+		checker.Add(health.Check{Name: "todo_storage", Run: pinger.Ping})
+	}
+	// SQL backends share the connection of the todo storage
+	if pinger, ok := apiKeyRepo.(repository.Pinger); ok {
+		checker.Add(health.Check{Name: "api_key_storage", Run: pinger.Ping})
+	}
+	return checker
+}
+
+// newAuthenticators creates the authentication methods enabled in the config
+func newAuthenticators(cfg *config.Config, apiKeyService services.APIKeyService) ([]appmiddleware.Authenticator, error) {
+	authenticators := []appmiddleware.Authenticator{appmiddleware.APIKey(apiKeyService)}
+
+	if cfg.JWTJWKS != "" || cfg.JWTJWKSFile != "" {
+		var keys *auth.JWKS
+		var err error
+		if cfg.JWTJWKSFile != "" {
+			keys, err = auth.LoadJWKSFile(cfg.JWTJWKSFile)
+		} else {
+			keys, err = auth.ParseJWKS([]byte(cfg.JWTJWKS))
+		}
+		if err != nil {
+			return nil, err
+		}
+		verifier := auth.NewJWTVerifier(keys, auth.JWTOptions{
+			Issuer:   cfg.JWTIssuer,
+			Audience: cfg.JWTAudience,
+			Leeway:   cfg.JWTLeeway,
+		})
+		authenticators = append(authenticators, appmiddleware.JWT(verifier))
+	}
+
+	if cfg.DevAuth() {
+		authenticators = append(authenticators, appmiddleware.BearerToken(auth.DevUserStore()))
+	}
+	if cfg.AuthUsersFile != "" {
+		userStore, err := auth.LoadUserStore(cfg.AuthUsersFile)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, appmiddleware.BearerToken(userStore))
+		if userStore.HasPasswords() {
+			lockout := auth.NewLockout(cfg.BasicAuthMaxFailures, cfg.BasicAuthLockout)
+			authenticators = append(authenticators, appmiddleware.BasicAuth(userStore, lockout))
+		}
+	}
+
+	return authenticators, nil
+}
@@ -0,0 +1,122 @@
+// Package apperror defines the kinds of errors services and repositories
+// return, so the HTTP layer can map them to status codes in one place.
+package apperror
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Kind classifies an error by how the caller should react to it
+type Kind int
+
+const (
+	// KindInternal is a bug or an unexpected failure, the default for errors
+	// without a kind
+	KindInternal Kind = iota
+	// KindNotFound means the requested resource does not exist
+	KindNotFound
+	// KindConflict means the request conflicts with the current state of the
+	// resource, e.g. a concurrent update
+	KindConflict
+	// KindPreconditionFailed means a conditional request header did not match
+	KindPreconditionFailed
+	// KindValidation means the request is malformed or invalid
+	KindValidation
+	// KindUnauthorized means the caller is not authenticated
+	KindUnauthorized
+	// KindForbidden means the caller may not perform the request
+	KindForbidden
+	// KindUnavailable means a dependency is temporarily unavailable and the
+	// request may be retried
+	KindUnavailable
+)
+
+// Error is an error of a given kind. Its message is safe to show to
+// clients, the wrapped error is a cause that is only logged or details such
+// as field errors.
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns an error of kind with a client safe message
+func New(kind Kind, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+func NotFound(message string) *Error {
+	return New(KindNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(KindConflict, message)
+}
+
+func PreconditionFailed(message string) *Error {
+	return New(KindPreconditionFailed, message)
+}
+
+func Validation(message string) *Error {
+	return New(KindValidation, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(KindUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(KindForbidden, message)
+}
+
+// Invalid wraps an error describing invalid input, such as the field errors
+// of a request, keeping its message
+func Invalid(err error) *Error {
+	return &Error{Kind: KindValidation, Message: err.Error(), Err: err}
+}
+
+// Unavailable wraps the failure of a dependency, err is not shown to clients
+func Unavailable(message string, err error) *Error {
+	return &Error{Kind: KindUnavailable, Message: message, Err: err}
+}
+
+// KindOf returns the kind of the first Error in the chain of err,
+// KindInternal if there is none
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+// Status returns the HTTP status code for errors of kind
+func (k Kind) Status() int {
+	switch k {
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case KindValidation:
+		return http.StatusBadRequest
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
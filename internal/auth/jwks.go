@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+// jwksReloadInterval limits how often a file-based key set is re-read when
+// a token references an unknown key id
+const jwksReloadInterval = time.Minute
+
+// jwk is a JSON Web Key (RFC 7517). Only RSA and symmetric (oct) signing
+// keys are supported.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// RSA public key
+	N string `json:"n"`
+	E string `json:"e"`
+	// Symmetric key
+	K string `json:"k"`
+}
+
+// verificationKey is a parsed key and the signing algorithm it verifies
+type verificationKey struct {
+	alg string
+	key interface{}
+}
+
+// JWKS is a set of keys used to verify JWT signatures. RSA keys verify
+// RS256 and symmetric keys verify HS256 tokens.
+type JWKS struct {
+	mu       sync.RWMutex
+	keys     map[string]verificationKey
+	path     string
+	loadedAt time.Time
+}
+
+// ParseJWKS parses a JSON Web Key Set document
+func ParseJWKS(data []byte) (*JWKS, error) {
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	return &JWKS{keys: keys}, nil
+}
+
+// LoadJWKSFile reads a JSON Web Key Set from path. The file is read again
+// when a token references a key id that is not in the set, so keys can be
+// rotated without a restart.
+func LoadJWKSFile(path string) (*JWKS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read JWKS file: %w", err)
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse JWKS file %s: %w", path, err)
+	}
+
+	return &JWKS{keys: keys, path: path, loadedAt: time.Now()}, nil
+}
+
+// lookup returns the key for kid. Tokens without a kid are accepted when
+// the set holds exactly one key.
+func (s *JWKS) lookup(kid string) (verificationKey, bool) {
+	s.mu.RLock()
+	key, ok := s.find(kid)
+	stale := !ok && s.path != "" && time.Since(s.loadedAt) > jwksReloadInterval
+	s.mu.RUnlock()
+
+	if !stale {
+		return key, ok
+	}
+
+	// Unknown key id, the keys may have been rotated
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadedAt = time.Now()
+	if data, err := os.ReadFile(s.path); err == nil {
+		if keys, err := parseJWKS(data); err == nil {
+			s.keys = keys
+		}
+	}
+	return s.find(kid)
+}
+
+func (s *JWKS) find(kid string) (verificationKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+func parseJWKS(data []byte) (map[string]verificationKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]verificationKey)
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(k)
+		if err != nil {
+			return nil, fmt.Errorf("key %d (kid %q): %w", i, k.Kid, err)
+		}
+		if _, dup := keys[k.Kid]; dup {
+			return nil, fmt.Errorf("key %d: duplicate kid %q", i, k.Kid)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS contains no signing keys")
+	}
+
+	return keys, nil
+}
+
+func parseJWK(k jwk) (verificationKey, error) {
+	switch k.Kty {
+	case "RSA":
+		if k.Alg != "" && k.Alg != "RS256" {
+			return verificationKey{}, fmt.Errorf("unsupported alg %q for RSA key", k.Alg)
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil || len(n) == 0 {
+			return verificationKey{}, errors.New("invalid modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return verificationKey{}, errors.New("invalid exponent")
+		}
+		return verificationKey{alg: "RS256", key: &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}}, nil
+	case "oct":
+		if k.Alg != "" && k.Alg != "HS256" {
+			return verificationKey{}, fmt.Errorf("unsupported alg %q for oct key", k.Alg)
+		}
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) < 32 {
+			return verificationKey{}, errors.New("HS256 keys must be at least 256 bits")
+		}
+		return verificationKey{alg: "HS256", key: secret}, nil
+	default:
+		return verificationKey{}, fmt.Errorf("unsupported kty %q", k.Kty)
+	}
+}
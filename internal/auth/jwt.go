@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTOptions are the claim checks applied to every token
+type JWTOptions struct {
+	// Issuer is the required iss claim, not checked when empty
+	Issuer string
+	// Audience must be listed in the aud claim, not checked when empty
+	Audience string
+	// Leeway allows for clock skew when checking exp and nbf
+	Leeway time.Duration
+}
+
+// JWTVerifier authenticates users by signed JWT bearer tokens
+type JWTVerifier struct {
+	keys   *JWKS
+	parser *jwt.Parser
+}
+
+func NewJWTVerifier(keys *JWKS, opts JWTOptions) *JWTVerifier {
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "RS256"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(opts.Leeway),
+	}
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+
+	return &JWTVerifier{
+		keys:   keys,
+		parser: jwt.NewParser(parserOpts...),
+	}
+}
+
+// Verify checks the signature and the exp, nbf, iss and aud claims of
+// token and returns its subject as the user
+func (v *JWTVerifier) Verify(token string) (*User, error) {
+	var claims jwt.RegisteredClaims
+	_, err := v.parser.ParseWithClaims(token, &claims, v.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	return &User{ID: claims.Subject}, nil
+}
+
+// keyFunc selects the verification key by kid and refuses keys meant for
+// another algorithm, so an RSA public key can never be used as HMAC secret
+func (v *JWTVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := v.keys.lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != key.alg {
+		return nil, fmt.Errorf("key %q does not verify %s tokens", kid, token.Method.Alg())
+	}
+	return key.key, nil
+}
@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testHMACSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func newTestRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) jwk {
+	return jwk{
+		Kty: "RSA", Kid: kid, Alg: "RS256", Use: "sig",
+		N: base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func octJWK(kid string, secret []byte) jwk {
+	return jwk{Kty: "oct", Kid: kid, Alg: "HS256", K: base64.RawURLEncoding.EncodeToString(secret)}
+}
+
+func jwksDocument(t *testing.T, keys ...jwk) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string][]jwk{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// signToken signs claims with method and key, setting kid unless empty
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestJWTVerifier(t *testing.T) {
+	rsaKey := newTestRSAKey(t)
+	otherRSAKey := newTestRSAKey(t)
+	keys, err := ParseJWKS(jwksDocument(t, rsaJWK("rsa", &rsaKey.PublicKey), octJWK("hmac", testHMACSecret)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewJWTVerifier(keys, JWTOptions{Issuer: "https://issuer.example", Audience: "echo-todo", Leeway: 30 * time.Second})
+
+	// An attacker knowing the RSA public key signs HS256 tokens with it
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	now := time.Now()
+	claims := func(changes jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"sub": "alice",
+			"iss": "https://issuer.example",
+			"aud": "echo-todo",
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		for name, value := range changes {
+			if value == nil {
+				delete(c, name)
+			} else {
+				c[name] = value
+			}
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"RS256", signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(nil)), false},
+		{"HS256", signToken(t, jwt.SigningMethodHS256, "hmac", testHMACSecret, claims(nil)), false},
+		{"audience list", signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"aud": []string{"other", "echo-todo"}})), false},
+
+		{"RS256 bad signature", signToken(t, jwt.SigningMethodRS256, "rsa", otherRSAKey, claims(nil)), true},
+		{"HS256 bad signature", signToken(t, jwt.SigningMethodHS256, "hmac", []byte("fedcba9876543210fedcba9876543210"), claims(nil)), true},
+		{"RSA public key PEM as HS256 secret", signToken(t, jwt.SigningMethodHS256, "rsa", publicPEM, claims(nil)), true},
+		{"RSA public key DER as HS256 secret", signToken(t, jwt.SigningMethodHS256, "rsa", publicDER, claims(nil)), true},
+		{"RSA modulus as HS256 secret", signToken(t, jwt.SigningMethodHS256, "rsa", rsaKey.N.Bytes(), claims(nil)), true},
+		{"alg none", signToken(t, jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType, claims(nil)), true},
+		{"alg none without kid", signToken(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, claims(nil)), true},
+		{"RS384", signToken(t, jwt.SigningMethodRS384, "rsa", rsaKey, claims(nil)), true},
+
+		{"expired within leeway", signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"exp": now.Add(-10 * time.Second).Unix()})), false},
+		{"expired", signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"exp": now.Add(-time.Minute).Unix()})), true},
+		{"no exp", signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"exp": nil})), true},
+		{"nbf within leeway", signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"nbf": now.Add(10 * time.Second).Unix()})), false},
+		{"nbf in the future", signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"nbf": now.Add(time.Minute).Unix()})), true},
+
+		{"other issuer", signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"iss": "https://other.example"})), true},
+		{"no issuer", signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"iss": nil})), true},
+		{"other audience", signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"aud": "other"})), true},
+		{"no audience", signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"aud": nil})), true},
+		{"no subject", signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims(jwt.MapClaims{"sub": nil})), true},
+
+		{"unknown kid", signToken(t, jwt.SigningMethodRS256, "rotated", rsaKey, claims(nil)), true},
+		// Tokens without kid need a set with a single key
+		{"no kid", signToken(t, jwt.SigningMethodRS256, "", rsaKey, claims(nil)), true},
+		{"garbage", "a.b.c", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := verifier.Verify(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (user.ID != "alice" || user.Scopes != nil) {
+				t.Errorf("Verify() = %+v", user)
+			}
+		})
+	}
+}
+
+func TestJWTVerifierSingleKey(t *testing.T) {
+	keys, err := ParseJWKS(jwksDocument(t, octJWK("hmac", testHMACSecret)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewJWTVerifier(keys, JWTOptions{})
+	token := signToken(t, jwt.SigningMethodHS256, "", testHMACSecret, jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()})
+	if _, err := verifier.Verify(token); err != nil {
+		t.Errorf("Verify(no kid) error = %v", err)
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	key := newTestRSAKey(t)
+	rsaKey := rsaJWK("rsa", &key.PublicKey)
+	withAlg := func(k jwk, alg string) jwk {
+		k.Alg = alg
+		return k
+	}
+	encryption := rsaKey
+	encryption.Kid, encryption.Use = "enc", "enc"
+	badExponent := rsaKey
+	badExponent.E = "!"
+
+	tests := []struct {
+		name     string
+		keys     []jwk
+		wantKids []string
+		wantErr  bool
+	}{
+		{"RSA and oct", []jwk{rsaKey, octJWK("hmac", testHMACSecret)}, []string{"rsa", "hmac"}, false},
+		{"without alg", []jwk{withAlg(rsaKey, ""), withAlg(octJWK("hmac", testHMACSecret), "")}, []string{"rsa", "hmac"}, false},
+		{"encryption keys are skipped", []jwk{rsaKey, encryption}, []string{"rsa"}, false},
+		{"only encryption keys", []jwk{encryption}, nil, true},
+		{"empty", nil, nil, true},
+		{"RSA key for HS256", []jwk{withAlg(rsaKey, "HS256")}, nil, true},
+		{"oct key for RS256", []jwk{withAlg(octJWK("hmac", testHMACSecret), "RS256")}, nil, true},
+		{"short HMAC secret", []jwk{octJWK("hmac", testHMACSecret[:31])}, nil, true},
+		{"bad exponent", []jwk{badExponent}, nil, true},
+		{"EC key", []jwk{{Kty: "EC", Kid: "ec"}}, nil, true},
+		{"duplicate kid", []jwk{rsaKey, octJWK("rsa", testHMACSecret)}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := ParseJWKS(jwksDocument(t, tt.keys...))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseJWKS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(set.keys) != len(tt.wantKids) {
+				t.Errorf("ParseJWKS() has %d keys, want %v", len(set.keys), tt.wantKids)
+			}
+			for _, kid := range tt.wantKids {
+				if _, ok := set.keys[kid]; !ok {
+					t.Errorf("key %q is missing", kid)
+				}
+			}
+		})
+	}
+}
+
+// TestJWKSReload checks a key set file is read again for unknown key ids,
+// at most once per jwksReloadInterval
+func TestJWKSReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	write := func(keys ...jwk) {
+		t.Helper()
+		if err := os.WriteFile(path, jwksDocument(t, keys...), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	secret2 := []byte("fedcba9876543210fedcba9876543210")
+	write(octJWK("key1", testHMACSecret))
+
+	keys, err := LoadJWKSFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewJWTVerifier(keys, JWTOptions{})
+	verify := func(kid string, secret []byte) error {
+		t.Helper()
+		_, err := verifier.Verify(signToken(t, jwt.SigningMethodHS256, kid, secret,
+			jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}))
+		return err
+	}
+	expireReload := func() {
+		keys.mu.Lock()
+		keys.loadedAt = time.Now().Add(-2 * jwksReloadInterval)
+		keys.mu.Unlock()
+	}
+
+	if err := verify("key1", testHMACSecret); err != nil {
+		t.Fatalf("Verify(key1) error = %v", err)
+	}
+
+	// The file was just read, the new key is not picked up yet
+	write(octJWK("key1", testHMACSecret), octJWK("key2", secret2))
+	if err := verify("key2", secret2); err == nil {
+		t.Fatal("key set was read again within the reload interval")
+	}
+
+	expireReload()
+	if err := verify("key2", secret2); err != nil {
+		t.Fatalf("Verify(key2) after reload error = %v", err)
+	}
+
+	// A broken file keeps the keys loaded before
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	expireReload()
+	if err := verify("key3", secret2); err == nil {
+		t.Error("unknown key accepted")
+	}
+	if err := verify("key2", secret2); err != nil {
+		t.Errorf("Verify(key2) after failed reload error = %v", err)
+	}
+
+	// Removed keys stop verifying after the next reload
+	write(octJWK("key3", secret2))
+	expireReload()
+	if err := verify("key3", secret2); err != nil {
+		t.Fatalf("Verify(key3) error = %v", err)
+	}
+	if err := verify("key1", testHMACSecret); err == nil {
+		t.Error("removed key still verifies")
+	}
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lockoutMaxEntries caps the number of tracked keys. Failed logins for
+// unknown usernames are tracked too, so a user's existence is not revealed
+// by whether it can be locked, and the cap keeps credential stuffing with
+// random usernames from growing memory without limit. Above it, the least
+// recently failed key that is not locked is forgotten. A locked key is never
+// forgotten before its lockout ends, so when every tracked key is locked the
+// failure of a new key is not tracked.
+const lockoutMaxEntries = 10000
+
+// Lockout blocks a key, e.g. a username, after too many consecutive failed
+// login attempts. Failures older than the lockout duration are forgotten.
+type Lockout struct {
+	mu          sync.Mutex
+	maxFailures int
+	duration    time.Duration
+	maxEntries  int
+	entries     map[string]*list.Element
+	// recent orders the entries by their last failure, most recent first
+	recent *list.List
+	now    func() time.Time
+}
+
+type lockoutEntry struct {
+	key         string
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// NewLockout locks a key for duration after maxFailures failed attempts
+func NewLockout(maxFailures int, duration time.Duration) *Lockout {
+	return &Lockout{
+		maxFailures: maxFailures,
+		duration:    duration,
+		maxEntries:  lockoutMaxEntries,
+		entries:     make(map[string]*list.Element),
+		recent:      list.New(),
+		now:         time.Now,
+	}
+}
+
+// Locked reports whether key is currently locked out
+func (l *Lockout) Locked(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.entries[key]
+	return ok && l.now().Before(elem.Value.(*lockoutEntry).lockedUntil)
+}
+
+// Fail records a failed attempt for key
+func (l *Lockout) Fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var entry *lockoutEntry
+	if elem, ok := l.entries[key]; ok {
+		entry = elem.Value.(*lockoutEntry)
+		if now.Sub(entry.lastFailure) > l.duration {
+			*entry = lockoutEntry{key: key}
+		}
+		l.recent.MoveToFront(elem)
+	} else {
+		if !l.evict(now) {
+			return
+		}
+		entry = &lockoutEntry{key: key}
+		l.entries[key] = l.recent.PushFront(entry)
+	}
+
+	entry.failures++
+	entry.lastFailure = now
+	if entry.failures >= l.maxFailures {
+		entry.lockedUntil = now.Add(l.duration)
+		entry.failures = 0
+	}
+}
+
+// Reset forgets the failed attempts of key after a successful login
+func (l *Lockout) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.entries[key]; ok {
+		l.recent.Remove(elem)
+		delete(l.entries, key)
+	}
+}
+
+// evict makes room for a new entry and reports whether there is room:
+// expired entries are dropped, then the least recently failed entries that
+// are not locked while the map is full. Entries are ordered by their last
+// failure, so the expired ones are all at the back.
+func (l *Lockout) evict(now time.Time) bool {
+	for elem := l.recent.Back(); elem != nil; elem = l.recent.Back() {
+		entry := elem.Value.(*lockoutEntry)
+		if now.Sub(entry.lastFailure) <= l.duration || now.Before(entry.lockedUntil) {
+			break
+		}
+		l.recent.Remove(elem)
+		delete(l.entries, entry.key)
+	}
+
+	for elem := l.recent.Back(); elem != nil && len(l.entries) >= l.maxEntries; {
+		entry := elem.Value.(*lockoutEntry)
+		prev := elem.Prev()
+		if !now.Before(entry.lockedUntil) {
+			l.recent.Remove(elem)
+			delete(l.entries, entry.key)
+		}
+		elem = prev
+	}
+	return len(l.entries) < l.maxEntries
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newTestLockout returns a lockout whose clock is advanced by the returned
+// function
+func newTestLockout(maxFailures, maxEntries int) (*Lockout, func(d time.Duration)) {
+	l := NewLockout(maxFailures, time.Minute)
+	l.maxEntries = maxEntries
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func failTimes(l *Lockout, key string, n int) {
+	for i := 0; i < n; i++ {
+		l.Fail(key)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	l, advance := newTestLockout(3, lockoutMaxEntries)
+
+	failTimes(l, "alice", 2)
+	if l.Locked("alice") {
+		t.Fatal("locked before the maximum number of failures")
+	}
+	l.Fail("alice")
+	if !l.Locked("alice") {
+		t.Fatal("not locked after the maximum number of failures")
+	}
+	if l.Locked("bob") {
+		t.Error("other key locked")
+	}
+
+	advance(time.Minute - time.Second)
+	if !l.Locked("alice") {
+		t.Error("lockout ended early")
+	}
+	advance(time.Second)
+	if l.Locked("alice") {
+		t.Error("still locked after the lockout duration")
+	}
+
+	// A success forgets earlier failures
+	failTimes(l, "bob", 2)
+	l.Reset("bob")
+	failTimes(l, "bob", 2)
+	if l.Locked("bob") {
+		t.Error("locked although failures were reset")
+	}
+
+	// So does waiting longer than the lockout duration between failures
+	failTimes(l, "carol", 2)
+	advance(2 * time.Minute)
+	failTimes(l, "carol", 2)
+	if l.Locked("carol") {
+		t.Error("locked by failures older than the lockout duration")
+	}
+	l.Fail("carol")
+	if !l.Locked("carol") {
+		t.Error("not locked by consecutive recent failures")
+	}
+}
+
+func TestLockoutEvict(t *testing.T) {
+	t.Run("least recently failed", func(t *testing.T) {
+		l, advance := newTestLockout(2, 2)
+		l.Fail("alice")
+		advance(time.Second)
+		failTimes(l, "bob", 2)
+		advance(time.Second)
+		l.Fail("carol")
+
+		if _, ok := l.entries["alice"]; ok {
+			t.Error("least recently failed key was kept")
+		}
+		if !l.Locked("bob") {
+			t.Error("locked key was forgotten")
+		}
+		l.Fail("carol")
+		if !l.Locked("carol") {
+			t.Error("failure of new key was not tracked")
+		}
+	})
+
+	t.Run("locked keys are kept", func(t *testing.T) {
+		l, advance := newTestLockout(2, 2)
+		failTimes(l, "alice", 2)
+		failTimes(l, "bob", 2)
+
+		// Failures of many other keys cannot unlock alice and bob
+		for i := 0; i < 10; i++ {
+			failTimes(l, fmt.Sprintf("user%d", i), 2)
+		}
+		if !l.Locked("alice") || !l.Locked("bob") {
+			t.Error("locked key was evicted")
+		}
+		if len(l.entries) != 2 {
+			t.Errorf("%d keys tracked, want 2", len(l.entries))
+		}
+
+		// After the lockouts end there is room again
+		advance(time.Minute)
+		failTimes(l, "carol", 2)
+		if !l.Locked("carol") {
+			t.Error("failure of new key was not tracked after lockouts ended")
+		}
+	})
+
+	t.Run("expired entries", func(t *testing.T) {
+		l, advance := newTestLockout(2, 10)
+		l.Fail("alice")
+		failTimes(l, "bob", 2)
+		advance(2 * time.Minute)
+		l.Fail("carol")
+		if len(l.entries) != 1 || l.recent.Len() != 1 {
+			t.Errorf("expired keys kept: %d entries, %d in list", len(l.entries), l.recent.Len())
+		}
+	})
+}
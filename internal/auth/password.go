@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordHash verifies passwords against a stored bcrypt or argon2id hash
+type passwordHash interface {
+	verify(password string) bool
+}
+
+// parsePasswordHash accepts bcrypt hashes ("$2a$", "$2b$", "$2y$") and
+// argon2id hashes in PHC string format
+// ("$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>").
+func parsePasswordHash(s string) (passwordHash, error) {
+	switch {
+	case strings.HasPrefix(s, "$2a$"), strings.HasPrefix(s, "$2b$"), strings.HasPrefix(s, "$2y$"):
+		if _, err := bcrypt.Cost([]byte(s)); err != nil {
+			return nil, fmt.Errorf("invalid bcrypt hash: %w", err)
+		}
+		return bcryptHash(s), nil
+	case strings.HasPrefix(s, "$argon2id$"):
+		return parseArgon2idHash(s)
+	default:
+		return nil, errors.New("unsupported password hash, expected bcrypt or argon2id")
+	}
+}
+
+type bcryptHash string
+
+func (h bcryptHash) verify(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(h), []byte(password)) == nil
+}
+
+type argon2idHash struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+func parseArgon2idHash(s string) (passwordHash, error) {
+	invalid := errors.New("invalid argon2id hash")
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	parts := strings.Split(s, "$")
+	if len(parts) != 6 {
+		return nil, invalid
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+
+	var h argon2idHash
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.time, &h.threads); err != nil ||
+		h.time == 0 || h.threads == 0 {
+		return nil, invalid
+	}
+
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil || len(h.salt) == 0 {
+		return nil, invalid
+	}
+	if h.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(h.key) == 0 {
+		return nil, invalid
+	}
+
+	return h, nil
+}
+
+func (h argon2idHash) verify(password string) bool {
+	key := argon2.IDKey([]byte(password), h.salt, h.time, h.memory, h.threads, uint32(len(h.key)))
+	return subtle.ConstantTimeCompare(key, h.key) == 1
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     passwordHash
+)
+
+// dummyPasswordHash is verified for unknown users so that the response time
+// does not reveal whether a user exists
+func dummyPasswordHash() passwordHash {
+	dummyHashOnce.Do(func() {
+		hash, err := bcrypt.GenerateFromPassword([]byte("echo-todo"), bcrypt.DefaultCost)
+		if err != nil {
+			panic(err)
+		}
+		dummyHash = bcryptHash(hash)
+	})
+	return dummyHash
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testBcryptHash(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+// testArgon2idHash encodes password the way the argon2 CLI does
+func testArgon2idHash(password string) string {
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte(password), salt, 1, 1024, 1, 32)
+	return fmt.Sprintf("$argon2id$v=%d$m=1024,t=1,p=1$%s$%s", argon2.Version,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestParsePasswordHash(t *testing.T) {
+	bcryptHash := testBcryptHash(t, "secret")
+	argon2Hash := testArgon2idHash("secret")
+	parts := strings.Split(argon2Hash, "$")
+
+	tests := []struct {
+		name    string
+		hash    string
+		wantErr bool
+	}{
+		{"bcrypt", bcryptHash, false},
+		{"bcrypt 2y", "$2y$" + strings.TrimPrefix(bcryptHash, "$2a$"), false},
+		{"argon2id", argon2Hash, false},
+		{"truncated bcrypt", bcryptHash[:20], true},
+		{"argon2i", strings.Replace(argon2Hash, "argon2id", "argon2i", 1), true},
+		{"argon2id version 16", strings.Replace(argon2Hash, "v=19", "v=16", 1), true},
+		{"argon2id without parameters", strings.Join(append(parts[:3:3], parts[4:]...), "$"), true},
+		{"argon2id zero time", strings.Replace(argon2Hash, "t=1", "t=0", 1), true},
+		{"argon2id bad salt", strings.Replace(argon2Hash, parts[4], "!!!", 1), true},
+		{"argon2id empty key", strings.TrimSuffix(argon2Hash, parts[5]), true},
+		{"plain text", "secret", true},
+		{"empty", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := parsePasswordHash(tt.hash)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePasswordHash(%q) error = %v, wantErr %v", tt.hash, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !hash.verify("secret") {
+				t.Error("correct password rejected")
+			}
+			if hash.verify("Secret") || hash.verify("") {
+				t.Error("wrong password accepted")
+			}
+		})
+	}
+}
+
+func TestAuthenticatePassword(t *testing.T) {
+	store, err := NewUserStore([]UserEntry{
+		{ID: "alice", PasswordHash: testBcryptHash(t, "alice-password")},
+		{ID: "bob", PasswordHash: testArgon2idHash("bob-password")},
+		{ID: "carol", TokenSHA256: HashToken("carol-token")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id, password string
+		ok           bool
+	}{
+		{"alice", "alice-password", true},
+		{"bob", "bob-password", true},
+		{"alice", "bob-password", false},
+		{"carol", "", false},
+		{"carol", "carol-token", false},
+		{"dave", "alice-password", false},
+	}
+	for _, tt := range tests {
+		user, ok := store.AuthenticatePassword(tt.id, tt.password)
+		if ok != tt.ok || (ok && (user.ID != tt.id || user.Scopes != nil)) {
+			t.Errorf("AuthenticatePassword(%s, %s) = %+v, %v, want ok %v", tt.id, tt.password, user, ok, tt.ok)
+		}
+	}
+}
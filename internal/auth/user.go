@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// User is an authenticated caller
+type User struct {
+	ID string
+	// Scopes limits what the caller may do, nil grants every scope
+	Scopes []string
+}
+
+// HasScope reports whether the user was granted scope
+func (u *User) HasScope(scope string) bool {
+	return u.Scopes == nil || slices.Contains(u.Scopes, scope)
+}
+
+type contextKey struct{}
+
+// WithUser returns a copy of ctx carrying user
+func WithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// UserFromContext returns the user stored by WithUser, if any
+func UserFromContext(ctx context.Context) (*User, bool) {
+	user, ok := ctx.Value(contextKey{}).(*User)
+	return user, ok && user != nil
+}
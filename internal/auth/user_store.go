@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UserEntry is a user in the users file. Bearer tokens are stored as the
+// hex encoded SHA-256 of the token and Basic auth passwords as a bcrypt or
+// argon2id hash, never in plain text.
+type UserEntry struct {
+	ID           string `yaml:"id"`
+	TokenSHA256  string `yaml:"token_sha256"`
+	PasswordHash string `yaml:"password_hash"`
+}
+
+type usersFile struct {
+	Users []UserEntry `yaml:"users"`
+}
+
+// UserStore holds the users allowed to call the API
+type UserStore struct {
+	users []storedUser
+}
+
+type storedUser struct {
+	id           string
+	tokenHash    []byte
+	passwordHash passwordHash
+}
+
+// LoadUserStore reads a YAML users file
+func LoadUserStore(path string) (*UserStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read users file: %w", err)
+	}
+
+	var file usersFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse users file %s: %w", path, err)
+	}
+
+	return NewUserStore(file.Users)
+}
+
+// DevUserToken is the bearer token of the user of DevUserStore
+const DevUserToken = "dev-token"
+
+// DevUserStore returns a store with the single user dev, authenticated by
+// the bearer token DevUserToken. It is only meant for local development.
+func DevUserStore() *UserStore {
+	hash := sha256.Sum256([]byte(DevUserToken))
+	return &UserStore{users: []storedUser{{id: "dev", tokenHash: hash[:]}}}
+}
+
+// NewUserStore validates entries and builds a store from them
+func NewUserStore(entries []UserEntry) (*UserStore, error) {
+	store := &UserStore{}
+	seen := make(map[string]bool)
+	for i, entry := range entries {
+		if entry.ID == "" {
+			return nil, fmt.Errorf("user %d: id is required", i)
+		}
+		if seen[entry.ID] {
+			return nil, fmt.Errorf("user %s: duplicate id", entry.ID)
+		}
+		seen[entry.ID] = true
+
+		user := storedUser{id: entry.ID}
+		if entry.TokenSHA256 != "" {
+			hash, err := hex.DecodeString(entry.TokenSHA256)
+			if err != nil || len(hash) != sha256.Size {
+				return nil, fmt.Errorf("user %s: token_sha256 must be a hex encoded SHA-256 hash", entry.ID)
+			}
+			user.tokenHash = hash
+		}
+		if entry.PasswordHash != "" {
+			hash, err := parsePasswordHash(entry.PasswordHash)
+			if err != nil {
+				return nil, fmt.Errorf("user %s: password_hash: %w", entry.ID, err)
+			}
+			user.passwordHash = hash
+		}
+		store.users = append(store.users, user)
+	}
+
+	if len(store.users) == 0 {
+		return nil, errors.New("users file must define at least one user")
+	}
+
+	return store, nil
+}
+
+// AuthenticateToken returns the user owning the bearer token
+func (s *UserStore) AuthenticateToken(token string) (*User, bool) {
+	hash := sha256.Sum256([]byte(token))
+
+	// Compare against every user so timing does not reveal a match position
+	var found *User
+	for _, u := range s.users {
+		if u.tokenHash != nil && subtle.ConstantTimeCompare(hash[:], u.tokenHash) == 1 && found == nil {
+			found = &User{ID: u.id}
+		}
+	}
+
+	return found, found != nil
+}
+
+// HasPasswords reports whether any user can authenticate with a password
+func (s *UserStore) HasPasswords() bool {
+	for _, u := range s.users {
+		if u.passwordHash != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthenticatePassword returns the user with the given id and password
+func (s *UserStore) AuthenticatePassword(id, password string) (*User, bool) {
+	var hash passwordHash
+	for _, u := range s.users {
+		if subtle.ConstantTimeCompare([]byte(u.id), []byte(id)) == 1 {
+			hash = u.passwordHash
+		}
+	}
+
+	// Unknown users and users without a password still pay for a hash
+	// comparison so timing does not reveal which users exist
+	if hash == nil {
+		dummyPasswordHash().verify(password)
+		return nil, false
+	}
+	if !hash.verify(password) {
+		return nil, false
+	}
+
+	return &User{ID: id}, true
+}
+
+// HashToken returns the token_sha256 value to store for token
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,537 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the application settings.
+//
+// Values are resolved in the following order, later sources overriding
+// earlier ones: built-in defaults, the config file (YAML or TOML), environment
+// variables and finally command line flags.
+type Config struct {
+	Port           string `yaml:"port" toml:"port"`
+	Environment    string `yaml:"environment" toml:"environment"`
+	LogLevel       string `yaml:"log_level" toml:"log_level"`
+	LogFormat      string `yaml:"log_format" toml:"log_format"`
+	StorageBackend string `yaml:"storage_backend" toml:"storage_backend"`
+	SQLitePath     string `yaml:"sqlite_path" toml:"sqlite_path"`
+
+	ServerReadTimeout    time.Duration `yaml:"server_read_timeout" toml:"server_read_timeout"`
+	ServerWriteTimeout   time.Duration `yaml:"server_write_timeout" toml:"server_write_timeout"`
+	ServerIdleTimeout    time.Duration `yaml:"server_idle_timeout" toml:"server_idle_timeout"`
+	ServerMaxHeaderBytes int           `yaml:"server_max_header_bytes" toml:"server_max_header_bytes"`
+	ShutdownTimeout      time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+
+	PostgresDSN             string        `yaml:"postgres_dsn" toml:"postgres_dsn"`
+	PostgresMaxOpenConns    int           `yaml:"postgres_max_open_conns" toml:"postgres_max_open_conns"`
+	PostgresMaxIdleConns    int           `yaml:"postgres_max_idle_conns" toml:"postgres_max_idle_conns"`
+	PostgresConnMaxLifetime time.Duration `yaml:"postgres_conn_max_lifetime" toml:"postgres_conn_max_lifetime"`
+	PostgresConnMaxIdleTime time.Duration `yaml:"postgres_conn_max_idle_time" toml:"postgres_conn_max_idle_time"`
+
+	AWSRegion      string `yaml:"aws_region" toml:"aws_region"`
+	TableName      string `yaml:"dynamodb_table_name" toml:"dynamodb_table_name"`
+	APIKeysTable   string `yaml:"dynamodb_api_keys_table_name" toml:"dynamodb_api_keys_table_name"`
+	AWSEndpointURL string `yaml:"aws_endpoint_url" toml:"aws_endpoint_url"`
+
+	AuthUsersFile string `yaml:"auth_users_file" toml:"auth_users_file"`
+
+	BasicAuthMaxFailures int           `yaml:"basic_auth_max_failures" toml:"basic_auth_max_failures"`
+	BasicAuthLockout     time.Duration `yaml:"basic_auth_lockout" toml:"basic_auth_lockout"`
+
+	JWTJWKS     string        `yaml:"jwt_jwks" toml:"jwt_jwks"`
+	JWTJWKSFile string        `yaml:"jwt_jwks_file" toml:"jwt_jwks_file"`
+	JWTIssuer   string        `yaml:"jwt_issuer" toml:"jwt_issuer"`
+	JWTAudience string        `yaml:"jwt_audience" toml:"jwt_audience"`
+	JWTLeeway   time.Duration `yaml:"jwt_leeway" toml:"jwt_leeway"`
+
+	TracingExporter     string  `yaml:"tracing_exporter" toml:"tracing_exporter"`
+	TracingOTLPEndpoint string  `yaml:"tracing_otlp_endpoint" toml:"tracing_otlp_endpoint"`
+	TracingSampleRatio  float64 `yaml:"tracing_sample_ratio" toml:"tracing_sample_ratio"`
+
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout" toml:"health_check_timeout"`
+	HealthCacheTTL     time.Duration `yaml:"health_cache_ttl" toml:"health_cache_ttl"`
+
+	TodoTitleMaxLength           int  `yaml:"todo_title_max_length" toml:"todo_title_max_length"`
+	TodoDescriptionMaxLength     int  `yaml:"todo_description_max_length" toml:"todo_description_max_length"`
+	RequestDisallowUnknownFields bool `yaml:"request_disallow_unknown_fields" toml:"request_disallow_unknown_fields"`
+	RequestMaxBodyBytes          int  `yaml:"request_max_body_bytes" toml:"request_max_body_bytes"`
+}
+
+// Storage backends selectable with StorageBackend
+const (
+	StorageDynamoDB = "dynamodb"
+	StorageSQLite   = "sqlite"
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
+// FieldError describes an invalid configuration key
+type FieldError struct {
+	Key     string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Key + ": " + e.Message
+}
+
+// ValidationError collects every invalid key found while loading the config
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		messages = append(messages, fe.Error())
+	}
+	return "invalid configuration: " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) add(key, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, &FieldError{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// setting binds a config key to its environment variable and command line flag
+type setting struct {
+	key   string
+	env   string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{key: "port", env: "PORT", usage: "HTTP listen port", set: setString(func(c *Config) *string { return &c.Port })},
+	{key: "environment", env: "ENVIRONMENT", usage: "deployment environment (development, staging, production)", set: setString(func(c *Config) *string { return &c.Environment })},
+	{key: "log_level", env: "LOG_LEVEL", usage: "minimum log level (debug, info, warn, error)", set: setString(func(c *Config) *string { return &c.LogLevel })},
+	{key: "log_format", env: "LOG_FORMAT", usage: "log output format (json, text)", set: setString(func(c *Config) *string { return &c.LogFormat })},
+	{key: "server_read_timeout", env: "SERVER_READ_TIMEOUT", usage: "maximum duration for reading a request including its body, e.g. 15s", set: setDuration(func(c *Config) *time.Duration { return &c.ServerReadTimeout })},
+	{key: "server_write_timeout", env: "SERVER_WRITE_TIMEOUT", usage: "maximum duration before timing out the write of a response, e.g. 30s", set: setDuration(func(c *Config) *time.Duration { return &c.ServerWriteTimeout })},
+	{key: "server_idle_timeout", env: "SERVER_IDLE_TIMEOUT", usage: "how long idle keep-alive connections are kept open, e.g. 60s", set: setDuration(func(c *Config) *time.Duration { return &c.ServerIdleTimeout })},
+	{key: "server_max_header_bytes", env: "SERVER_MAX_HEADER_BYTES", usage: "maximum size of request headers in bytes", set: setInt(func(c *Config) *int { return &c.ServerMaxHeaderBytes })},
+	{key: "shutdown_timeout", env: "SHUTDOWN_TIMEOUT", usage: "how long in-flight requests may run after SIGTERM or SIGINT, e.g. 20s", set: setDuration(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+	{key: "storage_backend", env: "STORAGE_BACKEND", usage: "todo storage backend (dynamodb, sqlite, postgres, memory)", set: setString(func(c *Config) *string { return &c.StorageBackend })},
+	{key: "sqlite_path", env: "SQLITE_PATH", usage: "SQLite database file for the sqlite storage backend", set: setString(func(c *Config) *string { return &c.SQLitePath })},
+	{key: "postgres_dsn", env: "POSTGRES_DSN", usage: "PostgreSQL connection string for the postgres storage backend", set: setString(func(c *Config) *string { return &c.PostgresDSN })},
+	{key: "postgres_max_open_conns", env: "POSTGRES_MAX_OPEN_CONNS", usage: "maximum open PostgreSQL connections", set: setInt(func(c *Config) *int { return &c.PostgresMaxOpenConns })},
+	{key: "postgres_max_idle_conns", env: "POSTGRES_MAX_IDLE_CONNS", usage: "maximum idle PostgreSQL connections", set: setInt(func(c *Config) *int { return &c.PostgresMaxIdleConns })},
+	{key: "postgres_conn_max_lifetime", env: "POSTGRES_CONN_MAX_LIFETIME", usage: "maximum lifetime of a PostgreSQL connection, e.g. 30m", set: setDuration(func(c *Config) *time.Duration { return &c.PostgresConnMaxLifetime })},
+	{key: "postgres_conn_max_idle_time", env: "POSTGRES_CONN_MAX_IDLE_TIME", usage: "maximum idle time of a PostgreSQL connection, e.g. 5m", set: setDuration(func(c *Config) *time.Duration { return &c.PostgresConnMaxIdleTime })},
+	{key: "aws_region", env: "AWS_REGION", usage: "AWS region", set: setString(func(c *Config) *string { return &c.AWSRegion })},
+	{key: "dynamodb_table_name", env: "DYNAMODB_TABLE_NAME", usage: "DynamoDB table storing todos", set: setString(func(c *Config) *string { return &c.TableName })},
+	{key: "dynamodb_api_keys_table_name", env: "DYNAMODB_API_KEYS_TABLE_NAME", usage: "DynamoDB table storing API keys", set: setString(func(c *Config) *string { return &c.APIKeysTable })},
+	{key: "aws_endpoint_url", env: "AWS_ENDPOINT_URL", usage: "custom AWS endpoint, e.g. DynamoDB Local", set: setString(func(c *Config) *string { return &c.AWSEndpointURL })},
+	{key: "auth_users_file", env: "AUTH_USERS_FILE", usage: "YAML file of API users and their hashed bearer tokens and passwords", set: setString(func(c *Config) *string { return &c.AuthUsersFile })},
+	{key: "basic_auth_max_failures", env: "BASIC_AUTH_MAX_FAILURES", usage: "failed Basic auth attempts before a user is locked out", set: setInt(func(c *Config) *int { return &c.BasicAuthMaxFailures })},
+	{key: "basic_auth_lockout", env: "BASIC_AUTH_LOCKOUT", usage: "how long a user stays locked out after failed Basic auth attempts", set: setDuration(func(c *Config) *time.Duration { return &c.BasicAuthLockout })},
+	{key: "jwt_jwks", env: "JWT_JWKS", usage: "inline JSON Web Key Set verifying JWT bearer tokens", set: setString(func(c *Config) *string { return &c.JWTJWKS })},
+	{key: "jwt_jwks_file", env: "JWT_JWKS_FILE", usage: "JSON Web Key Set file verifying JWT bearer tokens", set: setString(func(c *Config) *string { return &c.JWTJWKSFile })},
+	{key: "jwt_issuer", env: "JWT_ISSUER", usage: "required iss claim of JWTs", set: setString(func(c *Config) *string { return &c.JWTIssuer })},
+	{key: "jwt_audience", env: "JWT_AUDIENCE", usage: "required aud claim of JWTs", set: setString(func(c *Config) *string { return &c.JWTAudience })},
+	{key: "jwt_leeway", env: "JWT_LEEWAY", usage: "allowed clock skew for JWT exp and nbf, e.g. 30s", set: setDuration(func(c *Config) *time.Duration { return &c.JWTLeeway })},
+	{key: "tracing_exporter", env: "TRACING_EXPORTER", usage: "OpenTelemetry span exporter (none, stdout, otlp)", set: setString(func(c *Config) *string { return &c.TracingExporter })},
+	{key: "tracing_otlp_endpoint", env: "TRACING_OTLP_ENDPOINT", usage: "OTLP/HTTP collector URL for the otlp exporter, e.g. http://localhost:4318", set: setString(func(c *Config) *string { return &c.TracingOTLPEndpoint })},
+	{key: "tracing_sample_ratio", env: "TRACING_SAMPLE_RATIO", usage: "fraction of new traces recorded, between 0 and 1", set: setFloat(func(c *Config) *float64 { return &c.TracingSampleRatio })},
+	{key: "health_check_timeout", env: "HEALTH_CHECK_TIMEOUT", usage: "maximum duration of a single readiness check, e.g. 2s", set: setDuration(func(c *Config) *time.Duration { return &c.HealthCheckTimeout })},
+	{key: "health_cache_ttl", env: "HEALTH_CACHE_TTL", usage: "how long readiness check results are reused, e.g. 5s", set: setDuration(func(c *Config) *time.Duration { return &c.HealthCacheTTL })},
+	{key: "todo_title_max_length", env: "TODO_TITLE_MAX_LENGTH", usage: "maximum length of todo titles in characters", set: setInt(func(c *Config) *int { return &c.TodoTitleMaxLength })},
+	{key: "todo_description_max_length", env: "TODO_DESCRIPTION_MAX_LENGTH", usage: "maximum length of todo descriptions in characters", set: setInt(func(c *Config) *int { return &c.TodoDescriptionMaxLength })},
+	{key: "request_disallow_unknown_fields", env: "REQUEST_DISALLOW_UNKNOWN_FIELDS", usage: "reject JSON request bodies with fields the endpoint does not accept", set: setBool(func(c *Config) *bool { return &c.RequestDisallowUnknownFields })},
+	{key: "request_max_body_bytes", env: "REQUEST_MAX_BODY_BYTES", usage: "maximum size of JSON request bodies in bytes", set: setInt(func(c *Config) *int { return &c.RequestMaxBodyBytes })},
+}
+
+func setString(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func setInt(field func(c *Config) *int) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func setFloat(field func(c *Config) *float64) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		*field(c) = f
+		return nil
+	}
+}
+
+func setBool(field func(c *Config) *bool) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func setDuration(field func(c *Config) *time.Duration) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("must be a duration such as 30s or 5m")
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+// Default returns the built-in default settings
+func Default() *Config {
+	return &Config{
+		Port:           "1323",
+		Environment:    "development",
+		LogLevel:       "info",
+		LogFormat:      "json",
+		StorageBackend: StorageDynamoDB,
+		SQLitePath:     "echo-todo.db",
+
+		ServerReadTimeout:    15 * time.Second,
+		ServerWriteTimeout:   30 * time.Second,
+		ServerIdleTimeout:    60 * time.Second,
+		ServerMaxHeaderBytes: 1 << 20,
+		ShutdownTimeout:      20 * time.Second,
+
+		PostgresMaxOpenConns:    10,
+		PostgresMaxIdleConns:    5,
+		PostgresConnMaxLifetime: 30 * time.Minute,
+		PostgresConnMaxIdleTime: 5 * time.Minute,
+		AWSRegion:               "us-east-1",
+		TableName:               "todos",
+		APIKeysTable:            "api_keys",
+
+		BasicAuthMaxFailures: 5,
+		BasicAuthLockout:     15 * time.Minute,
+
+		JWTLeeway: 30 * time.Second,
+
+		TracingExporter:    "none",
+		TracingSampleRatio: 1,
+
+		HealthCheckTimeout: 2 * time.Second,
+		HealthCacheTTL:     5 * time.Second,
+
+		TodoTitleMaxLength:           200,
+		TodoDescriptionMaxLength:     2000,
+		RequestDisallowUnknownFields: true,
+		RequestMaxBodyBytes:          1 << 20,
+	}
+}
+
+// Load resolves the configuration from the process arguments and environment
+func Load() (*Config, error) {
+	return LoadFrom(os.Args[1:], os.LookupEnv)
+}
+
+// LoadFrom resolves the configuration from the given arguments and
+// environment lookup function and validates the result.
+func LoadFrom(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	verr := &ValidationError{}
+
+	fs := flag.NewFlagSet("echo-todo", flag.ContinueOnError)
+	configFile := fs.String("config", "", "path to a YAML or TOML config file (env CONFIG_FILE)")
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[s.key] = fs.String(flagName(s.key), "", fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// Config file
+	path := *configFile
+	if path == "" {
+		path, _ = lookupEnv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := loadFile(path, cfg, verr); err != nil {
+			return nil, err
+		}
+	}
+
+	// Environment variables
+	for _, s := range settings {
+		if value, ok := lookupEnv(s.env); ok {
+			if err := s.set(cfg, value); err != nil {
+				verr.add(s.key, "%s=%q: %v", s.env, value, err)
+			}
+		}
+	}
+
+	// Command line flags
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if flagName(s.key) == f.Name {
+				if err := s.set(cfg, *flagValues[s.key]); err != nil {
+					verr.add(s.key, "-%s=%q: %v", f.Name, f.Value.String(), err)
+				}
+			}
+		}
+	})
+
+	cfg.validate(verr)
+	if len(verr.Errors) > 0 {
+		return nil, verr
+	}
+
+	return cfg, nil
+}
+
+// Validate checks every setting and reports all invalid keys at once
+func (c *Config) Validate() error {
+	verr := &ValidationError{}
+	c.validate(verr)
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+func (c *Config) validate(verr *ValidationError) {
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		verr.add("port", "must be a number between 1 and 65535, got %q", c.Port)
+	}
+
+	switch c.Environment {
+	case "development", "staging", "production":
+	default:
+		verr.add("environment", "must be one of development, staging, production, got %q", c.Environment)
+	}
+
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		verr.add("log_level", "must be one of debug, info, warn, error, got %q", c.LogLevel)
+	}
+
+	switch c.LogFormat {
+	case "json", "text":
+	default:
+		verr.add("log_format", "must be one of json, text, got %q", c.LogFormat)
+	}
+
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"server_read_timeout", c.ServerReadTimeout},
+		{"server_write_timeout", c.ServerWriteTimeout},
+		{"server_idle_timeout", c.ServerIdleTimeout},
+		{"shutdown_timeout", c.ShutdownTimeout},
+	} {
+		if d.value <= 0 {
+			verr.add(d.key, "must be positive")
+		}
+	}
+	if c.ServerMaxHeaderBytes < 4096 {
+		verr.add("server_max_header_bytes", "must be at least 4096, got %d", c.ServerMaxHeaderBytes)
+	}
+
+	switch c.StorageBackend {
+	case StorageDynamoDB:
+		if c.AWSRegion == "" {
+			verr.add("aws_region", "is required for the dynamodb storage backend")
+		}
+		if c.TableName == "" {
+			verr.add("dynamodb_table_name", "is required for the dynamodb storage backend")
+		}
+		if c.APIKeysTable == "" {
+			verr.add("dynamodb_api_keys_table_name", "is required for the dynamodb storage backend")
+		}
+	case StorageSQLite:
+		if c.SQLitePath == "" {
+			verr.add("sqlite_path", "is required for the sqlite storage backend")
+		}
+	case StoragePostgres:
+		if c.PostgresDSN == "" {
+			verr.add("postgres_dsn", "is required for the postgres storage backend")
+		}
+	case StorageMemory:
+	default:
+		verr.add("storage_backend", "must be one of dynamodb, sqlite, postgres, memory, got %q", c.StorageBackend)
+	}
+
+	if c.PostgresMaxOpenConns < 1 {
+		verr.add("postgres_max_open_conns", "must be at least 1, got %d", c.PostgresMaxOpenConns)
+	}
+	if c.PostgresMaxIdleConns < 0 || c.PostgresMaxIdleConns > c.PostgresMaxOpenConns {
+		verr.add("postgres_max_idle_conns", "must be between 0 and postgres_max_open_conns, got %d", c.PostgresMaxIdleConns)
+	}
+	if c.PostgresConnMaxLifetime < 0 {
+		verr.add("postgres_conn_max_lifetime", "must not be negative")
+	}
+	if c.PostgresConnMaxIdleTime < 0 {
+		verr.add("postgres_conn_max_idle_time", "must not be negative")
+	}
+
+	if c.AWSEndpointURL != "" {
+		if u, err := url.Parse(c.AWSEndpointURL); err != nil || u.Scheme == "" || u.Host == "" {
+			verr.add("aws_endpoint_url", "must be an absolute URL, got %q", c.AWSEndpointURL)
+		}
+	}
+
+	if c.BasicAuthMaxFailures < 1 {
+		verr.add("basic_auth_max_failures", "must be at least 1")
+	}
+	if c.BasicAuthLockout <= 0 {
+		verr.add("basic_auth_lockout", "must be positive")
+	}
+	if c.JWTJWKS != "" && c.JWTJWKSFile != "" {
+		verr.add("jwt_jwks", "cannot be combined with jwt_jwks_file")
+	}
+	if c.JWTLeeway < 0 {
+		verr.add("jwt_leeway", "must not be negative")
+	}
+	switch c.TracingExporter {
+	case "none", "stdout", "otlp":
+	default:
+		verr.add("tracing_exporter", "must be one of none, stdout, otlp, got %q", c.TracingExporter)
+	}
+	if c.TracingOTLPEndpoint != "" {
+		if u, err := url.Parse(c.TracingOTLPEndpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			verr.add("tracing_otlp_endpoint", "must be an absolute URL, got %q", c.TracingOTLPEndpoint)
+		}
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		verr.add("tracing_sample_ratio", "must be between 0 and 1, got %g", c.TracingSampleRatio)
+	}
+	if c.HealthCheckTimeout <= 0 {
+		verr.add("health_check_timeout", "must be positive")
+	}
+	if c.HealthCacheTTL < 0 {
+		verr.add("health_cache_ttl", "must not be negative")
+	}
+	if c.TodoTitleMaxLength < 1 {
+		verr.add("todo_title_max_length", "must be at least 1, got %d", c.TodoTitleMaxLength)
+	}
+	if c.TodoDescriptionMaxLength < 1 {
+		verr.add("todo_description_max_length", "must be at least 1, got %d", c.TodoDescriptionMaxLength)
+	}
+	if c.RequestMaxBodyBytes < 1 {
+		verr.add("request_max_body_bytes", "must be at least 1, got %d", c.RequestMaxBodyBytes)
+	}
+	if c.AuthUsersFile == "" && c.JWTJWKS == "" && c.JWTJWKSFile == "" && !c.DevAuth() {
+		verr.add("auth_users_file", "is required unless JWT authentication is configured with jwt_jwks or jwt_jwks_file, or the memory storage backend is run in development")
+	}
+}
+
+// DevAuth reports whether the built-in development user authenticates
+// requests: the memory storage backend in development with neither a users
+// file nor JWT authentication, for a local run without any setup
+func (c *Config) DevAuth() bool {
+	return c.StorageBackend == StorageMemory && c.Environment == "development" &&
+		c.AuthUsersFile == "" && c.JWTJWKS == "" && c.JWTJWKSFile == ""
+}
+
+func loadFile(path string, cfg *Config, verr *ValidationError) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	var keys []string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		keys, err = decodeYAML(data, cfg, path, verr)
+	case ".toml":
+		var md toml.MetaData
+		if md, err = toml.Decode(string(data), cfg); err == nil {
+			for _, key := range md.Undecoded() {
+				keys = append(keys, key.String())
+			}
+		}
+	default:
+		return errors.New("config file must have a .yaml, .yml or .toml extension: " + path)
+	}
+	if err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	// Report keys that do not match any setting
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !knownKey(key) {
+			verr.add(key, "unknown key in %s", path)
+		}
+	}
+
+	return nil
+}
+
+// decodeYAML sets the settings of the YAML document data on cfg one key at
+// a time, so a value of the wrong type is reported for its key together with
+// the other invalid keys. It returns the keys found in the document.
+func decodeYAML(data []byte, cfg *Config, path string, verr *ValidationError) ([]string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected a mapping of settings", root.Line)
+	}
+
+	var keys []string
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key := root.Content[i].Value
+		keys = append(keys, key)
+		if !knownKey(key) {
+			continue
+		}
+		setting := &yaml.Node{Kind: yaml.MappingNode, Content: root.Content[i : i+2]}
+		if err := setting.Decode(cfg); err != nil {
+			var typeErr *yaml.TypeError
+			if errors.As(err, &typeErr) {
+				verr.add(key, "%s in %s", strings.Join(typeErr.Errors, "; "), path)
+				continue
+			}
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+func knownKey(key string) bool {
+	for _, s := range settings {
+		if s.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testEnv is a lookupEnv over vars. Unless overridden, the memory backend
+// in development needs no further settings.
+func testEnv(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		if value, ok := vars[key]; ok {
+			return value, true
+		}
+		switch key {
+		case "STORAGE_BACKEND":
+			return StorageMemory, true
+		}
+		return "", false
+	}
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := writeConfigFile(t, "config.yaml", "port: \"2000\"\nlog_level: debug\nserver_read_timeout: 20s\n")
+	tomlFile := writeConfigFile(t, "config.toml", "port = \"2000\"\nlog_level = \"debug\"\nserver_read_timeout = \"20s\"\n")
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		wantPort string
+	}{
+		{"defaults", nil, nil, "1323"},
+		{"yaml file", []string{"-config", yamlFile}, nil, "2000"},
+		{"toml file", []string{"-config", tomlFile}, nil, "2000"},
+		{"file from env", nil, map[string]string{"CONFIG_FILE": yamlFile}, "2000"},
+		{"env over file", []string{"-config", yamlFile}, map[string]string{"PORT": "3000"}, "3000"},
+		{"flag over env", []string{"-config", yamlFile, "-port", "4000"}, map[string]string{"PORT": "3000"}, "4000"},
+		{"flag over file", []string{"-config", tomlFile, "-port", "4000"}, nil, "4000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadFrom(tt.args, testEnv(tt.env))
+			if err != nil {
+				t.Fatalf("LoadFrom() error = %v", err)
+			}
+			if cfg.Port != tt.wantPort {
+				t.Errorf("Port = %q, want %q", cfg.Port, tt.wantPort)
+			}
+
+			// Settings no later source sets keep the value of the file or
+			// the default
+			wantLevel, wantTimeout := "info", 15*time.Second
+			if tt.wantPort != "1323" {
+				wantLevel, wantTimeout = "debug", 20*time.Second
+			}
+			if cfg.LogLevel != wantLevel || cfg.ServerReadTimeout != wantTimeout {
+				t.Errorf("LogLevel = %q, ServerReadTimeout = %s", cfg.LogLevel, cfg.ServerReadTimeout)
+			}
+			if cfg.ServerWriteTimeout != Default().ServerWriteTimeout {
+				t.Errorf("ServerWriteTimeout = %s, want the default", cfg.ServerWriteTimeout)
+			}
+		})
+	}
+
+	// -config takes precedence over CONFIG_FILE
+	other := writeConfigFile(t, "other.yaml", "port: \"5000\"\n")
+	cfg, err := LoadFrom([]string{"-config", other}, testEnv(map[string]string{"CONFIG_FILE": yamlFile}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "5000" {
+		t.Errorf("Port = %q, want the one of -config", cfg.Port)
+	}
+}
+
+// invalidKeys returns the keys reported by a *ValidationError
+func invalidKeys(t *testing.T, err error) []string {
+	t.Helper()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("error = %v, want a *ValidationError", err)
+	}
+	var keys []string
+	for _, fe := range verr.Errors {
+		keys = append(keys, fe.Key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestLoadValidationErrors(t *testing.T) {
+	file := writeConfigFile(t, "config.yaml", strings.Join([]string{
+		"server_max_header_bytes: big",
+		"server_idle_timeout: [1, 2]",
+		"log_format: xml",
+		"colour: red",
+		"",
+	}, "\n"))
+	args := []string{"-config", file, "-shutdown-timeout", "soon"}
+	env := map[string]string{
+		"PORT":                 "http",
+		"LOG_LEVEL":            "loud",
+		"TRACING_SAMPLE_RATIO": "2",
+	}
+
+	_, err := LoadFrom(args, testEnv(env))
+	want := []string{
+		"colour", "log_format", "log_level", "port", "server_idle_timeout",
+		"server_max_header_bytes", "shutdown_timeout", "tracing_sample_ratio",
+	}
+	if got := invalidKeys(t, err); !slices.Equal(got, want) {
+		t.Errorf("invalid keys = %v, want %v", got, want)
+	}
+	for _, part := range []string{"cannot unmarshal", "unknown key", `-shutdown-timeout="soon"`, `got "http"`} {
+		if !strings.Contains(err.Error(), part) {
+			t.Errorf("error %q does not mention %q", err, part)
+		}
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"missing", filepath.Join(t.TempDir(), "missing.yaml")},
+		{"extension", writeConfigFile(t, "config.json", "{}")},
+		{"yaml syntax", writeConfigFile(t, "config.yaml", "port: [")},
+		{"yaml list", writeConfigFile(t, "config.yaml", "- port\n")},
+		{"toml syntax", writeConfigFile(t, "config.toml", "port = ")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFrom([]string{"-config", tt.path}, testEnv(nil))
+			var verr *ValidationError
+			if err == nil || errors.As(err, &verr) {
+				t.Errorf("LoadFrom() error = %v, want a file error", err)
+			}
+		})
+	}
+
+	// An empty file sets nothing
+	cfg, err := LoadFrom([]string{"-config", writeConfigFile(t, "empty.yaml", "")}, testEnv(nil))
+	if err != nil || cfg.Port != "1323" {
+		t.Errorf("LoadFrom(empty file) = %+v, %v", cfg, err)
+	}
+}
+
+func TestValidateAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(c *Config)
+		devAuth bool
+		wantErr bool
+	}{
+		{"memory in development", func(c *Config) { c.StorageBackend = StorageMemory }, true, false},
+		{"memory in production", func(c *Config) { c.StorageBackend, c.Environment = StorageMemory, "production" }, false, true},
+		{"dynamodb without users", func(c *Config) {}, false, true},
+		{"users file", func(c *Config) { c.AuthUsersFile = "users.yaml" }, false, false},
+		{"jwks", func(c *Config) { c.JWTJWKSFile = "jwks.json" }, false, false},
+		{"both jwks", func(c *Config) { c.JWTJWKS, c.JWTJWKSFile = "{}", "jwks.json" }, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.change(cfg)
+			if cfg.DevAuth() != tt.devAuth {
+				t.Errorf("DevAuth() = %v, want %v", cfg.DevAuth(), tt.devAuth)
+			}
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
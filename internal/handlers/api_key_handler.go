@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"echo-todo/internal/apperror"
+	"echo-todo/internal/auth"
+	"echo-todo/internal/services"
+	"echo-todo/pkg/models"
+	"echo-todo/pkg/utils"
+)
+
+type APIKeyHandler struct {
+	apiKeyService services.APIKeyService
+	logger        *slog.Logger
+}
+
+func NewAPIKeyHandler(apiKeyService services.APIKeyService, logger *slog.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+		logger:        logger,
+	}
+}
+
+// CreateAPIKey creates a new API key
+// @Summary Create an API key
+// @Description Create a long-lived API key limited to the given scopes, which the caller must hold. The secret key is only returned in this response.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param key body models.CreateAPIKeyRequest true "Create API key request, scopes are todos:read, todos:write and api_keys:manage"
+// @Success 201 {object} utils.Response{data=models.CreatedAPIKey} "Successfully created"
+// @Failure 400 {object} utils.Response "Bad request"
+// @Failure 413 {object} utils.Response "Request body too large"
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Failure 403 {object} utils.Response "Missing api_keys:manage scope or a requested scope"
+// @Failure 500 {object} utils.Response "Internal server error"
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c echo.Context) error {
+	user, ok := auth.UserFromContext(c.Request().Context())
+	if !ok {
+		return apperror.Unauthorized("Authentication required")
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if err := utils.ValidateRequest(c, &req); err != nil {
+		return apperror.Invalid(err)
+	}
+
+	key, err := h.apiKeyService.CreateAPIKey(c.Request().Context(), user, &req)
+	if err != nil {
+		return err
+	}
+
+	return utils.SuccessResponse(c, http.StatusCreated, "API key created successfully", key)
+}
+
+// ListAPIKeys lists the API keys of the caller
+// @Summary List API keys
+// @Description List the API keys of the caller, including revoked keys. Secret keys are never returned.
+// @Tags api-keys
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]models.APIKey} "Successfully retrieved"
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Failure 403 {object} utils.Response "Missing api_keys:manage scope"
+// @Failure 500 {object} utils.Response "Internal server error"
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/api-keys [get]
+func (h *APIKeyHandler) ListAPIKeys(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return apperror.Unauthorized("Authentication required")
+	}
+
+	keys, err := h.apiKeyService.ListAPIKeys(c.Request().Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	return utils.SuccessResponse(c, http.StatusOK, "API keys retrieved successfully", keys)
+}
+
+// RotateAPIKey replaces the secret of an API key
+// @Summary Rotate an API key
+// @Description Replace the secret key of an API key, keeping its name and scopes. The old secret stops working immediately and the new one is only returned in this response. The caller must hold every scope of the key.
+// @Tags api-keys
+// @Produce json
+// @Param id path string true "API key ID"
+// @Success 200 {object} utils.Response{data=models.CreatedAPIKey} "Successfully rotated"
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Failure 403 {object} utils.Response "Missing api_keys:manage scope or a scope of the key"
+// @Failure 404 {object} utils.Response "API key not found"
+// @Failure 409 {object} utils.Response "API key is revoked"
+// @Failure 500 {object} utils.Response "Internal server error"
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/api-keys/{id}/rotate [post]
+func (h *APIKeyHandler) RotateAPIKey(c echo.Context) error {
+	user, ok := auth.UserFromContext(c.Request().Context())
+	if !ok {
+		return apperror.Unauthorized("Authentication required")
+	}
+
+	key, err := h.apiKeyService.RotateAPIKey(c.Request().Context(), user, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return utils.SuccessResponse(c, http.StatusOK, "API key rotated successfully", key)
+}
+
+// RevokeAPIKey permanently disables an API key
+// @Summary Revoke an API key
+// @Description Permanently disable an API key. Revoked keys stay listed with their revocation time. The caller must hold every scope of the key.
+// @Tags api-keys
+// @Produce json
+// @Param id path string true "API key ID"
+// @Success 200 {object} utils.Response{data=models.APIKey} "Successfully revoked"
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Failure 403 {object} utils.Response "Missing api_keys:manage scope or a scope of the key"
+// @Failure 404 {object} utils.Response "API key not found"
+// @Failure 500 {object} utils.Response "Internal server error"
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c echo.Context) error {
+	user, ok := auth.UserFromContext(c.Request().Context())
+	if !ok {
+		return apperror.Unauthorized("Authentication required")
+	}
+
+	key, err := h.apiKeyService.RevokeAPIKey(c.Request().Context(), user, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return utils.SuccessResponse(c, http.StatusOK, "API key revoked successfully", key)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"echo-todo/internal/health"
+)
+
+type HealthHandler struct {
+	checker *health.Checker
+	logger  *slog.Logger
+}
+
+func NewHealthHandler(checker *health.Checker, logger *slog.Logger) *HealthHandler {
+	return &HealthHandler{
+		checker: checker,
+		logger:  logger,
+	}
+}
+
+// Livez reports the process is running
+// @Summary Liveness probe
+// @Description Report the process is running. Dependencies are not checked, use /readyz for that.
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]string "Process is alive"
+// @Router /livez [get]
+func (h *HealthHandler) Livez(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": health.StatusPass})
+}
+
+// Readyz reports whether the dependencies needed to serve traffic are available
+// @Summary Readiness probe
+// @Description Run the readiness checks, such as storage reachability and config validity. Results are cached for a few seconds. Only the name and status of each check are reported, errors are logged by the server.
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report "Ready to serve traffic"
+// @Failure 503 {object} health.Report "At least one check failed"
+// @Router /readyz [get]
+func (h *HealthHandler) Readyz(c echo.Context) error {
+	report := h.checker.Check(c.Request().Context())
+	if report.Status != health.StatusPass {
+		for _, check := range report.Checks {
+			if check.Status != health.StatusPass {
+				h.logger.WarnContext(c.Request().Context(), "readiness check failed",
+					"check", check.Name, "error", check.Error, "latency", check.Latency)
+			}
+		}
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+	return c.JSON(http.StatusOK, report)
+}
@@ -1,10 +1,20 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
+	"echo-todo/internal/apperror"
+	"echo-todo/internal/auth"
 	"echo-todo/internal/services"
 	"echo-todo/pkg/models"
 	"echo-todo/pkg/utils"
@@ -12,14 +22,102 @@ import (
 
 type TodoHandler struct {
 	todoService services.TodoService
+	logger      *slog.Logger
 }
 
-func NewTodoHandler(todoService services.TodoService) *TodoHandler {
+func NewTodoHandler(todoService services.TodoService, logger *slog.Logger) *TodoHandler {
 	return &TodoHandler{
 		todoService: todoService,
+		logger:      logger,
 	}
 }
 
+// currentUserID returns the id of the authenticated caller
+func currentUserID(c echo.Context) (string, bool) {
+	user, ok := auth.UserFromContext(c.Request().Context())
+	if !ok {
+		return "", false
+	}
+	return user.ID, true
+}
+
+// setETag sends the version of todo as a strong entity tag
+func setETag(c echo.Context, todo *models.Todo) {
+	c.Response().Header().Set("ETag", strconv.Quote(strconv.Itoa(todo.Version)))
+}
+
+// parseIfMatch returns the versions listed in the If-Match header, nil when
+// any version is accepted. If-Match uses the strong comparison of RFC 9110,
+// so weak entity tags and tags of no version never match and are left out.
+func parseIfMatch(c echo.Context) ([]int, error) {
+	value := strings.TrimSpace(strings.Join(c.Request().Header.Values("If-Match"), ","))
+	if value == "" || value == "*" {
+		return nil, nil
+	}
+	malformed := apperror.PreconditionFailed(`If-Match must be "*" or a list of entity tags`)
+	versions := []int{}
+	for {
+		value = strings.TrimLeft(value, " \t,")
+		if value == "" {
+			return versions, nil
+		}
+		weak := strings.HasPrefix(value, "W/")
+		value = strings.TrimPrefix(value, "W/")
+		if !strings.HasPrefix(value, `"`) {
+			return nil, malformed
+		}
+		end := strings.IndexByte(value[1:], '"') + 1
+		if end < 1 {
+			return nil, malformed
+		}
+		tag := value[1:end]
+		value = strings.TrimLeft(value[end+1:], " \t")
+		if value != "" && value[0] != ',' {
+			return nil, malformed
+		}
+		if version, err := strconv.Atoi(tag); err == nil && version >= 1 && !weak {
+			versions = append(versions, version)
+		}
+	}
+}
+
+// ifMatchVersion returns the version a write must be based on to satisfy
+// the If-Match header, 0 when any version is accepted. A list of entity
+// tags is matched against the current version of the todo.
+func (h *TodoHandler) ifMatchVersion(c echo.Context, userID, id string) (int, error) {
+	versions, err := parseIfMatch(c)
+	switch {
+	case err != nil:
+		return 0, err
+	case versions == nil:
+		return 0, nil
+	case len(versions) == 1:
+		return versions[0], nil
+	case len(versions) > 1:
+		todo, err := h.todoService.GetTodoByID(c.Request().Context(), userID, id)
+		if err != nil {
+			return 0, err
+		}
+		if slices.Contains(versions, todo.Version) {
+			return todo.Version, nil
+		}
+	}
+	return 0, apperror.PreconditionFailed("If-Match does not match the current version of the todo")
+}
+
+// writeError replaces the version conflict of a write that lost against a
+// concurrent update with 412 if the caller sent If-Match, otherwise it is
+// kept as a 409. Other errors are returned unchanged.
+func writeError(c echo.Context, err error) error {
+	if !errors.Is(err, services.ErrVersionConflict) {
+		return err
+	}
+	if c.Request().Header.Get("If-Match") != "" {
+		return apperror.PreconditionFailed("Todo has been modified, fetch it again to get the current ETag")
+	}
+	return apperror.Conflict("Todo was modified concurrently, retry the request")
+}
+
 // CreateTodo creates a new todo
 // @Summary Create a new TODO
 // @Description Create a new TODO item
@@ -28,28 +126,41 @@ func NewTodoHandler(todoService services.TodoService) *TodoHandler {
 // @Produce json
 // @Param todo body models.CreateTodoRequest true "Create TODO request"
 // @Success 201 {object} utils.Response{data=models.Todo} "Successfully created"
+// @Header 201 {string} ETag "Version of the TODO"
 // @Failure 400 {object} utils.Response "Bad request"
+// @Failure 413 {object} utils.Response "Request body too large"
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Failure 403 {object} utils.Response "API key lacks the required scope"
 // @Failure 500 {object} utils.Response "Internal server error"
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /api/v1/todos [post]
 func (h *TodoHandler) CreateTodo(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return apperror.Unauthorized("Authentication required")
+	}
+
 	var req models.CreateTodoRequest
-	
+
 	// Bind request body
 	if err := c.Bind(&req); err != nil {
-		return utils.ValidationErrorResponse(c, "Invalid request format")
+		return err
 	}
-	
-	// Validate request
-	if err := utils.ValidateStruct(&req); err != nil {
-		return utils.ValidationErrorResponse(c, err.Error())
+
+	// Normalize and validate request
+	req.Normalize()
+	if err := utils.ValidateRequest(c, &req); err != nil {
+		return apperror.Invalid(err)
 	}
-	
+
 	// Create todo via service
-	todo, err := h.todoService.CreateTodo(c.Request().Context(), &req)
+	todo, err := h.todoService.CreateTodo(c.Request().Context(), userID, &req)
 	if err != nil {
-		return utils.InternalErrorResponse(c, "Failed to create todo")
+		return err
 	}
-	
+
+	setETag(c, todo)
 	return utils.SuccessResponse(c, http.StatusCreated, "Todo created successfully", todo)
 }
 
@@ -60,122 +171,385 @@ func (h *TodoHandler) CreateTodo(c echo.Context) error {
 // @Produce json
 // @Param id path string true "TODO ID"
 // @Success 200 {object} utils.Response{data=models.Todo} "Successfully retrieved"
+// @Header 200 {string} ETag "Version of the TODO, send it as If-Match to update or delete it"
 // @Failure 400 {object} utils.Response "Bad request"
 // @Failure 404 {object} utils.Response "TODO not found"
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Failure 403 {object} utils.Response "API key lacks the required scope"
 // @Failure 500 {object} utils.Response "Internal server error"
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /api/v1/todos/{id} [get]
 func (h *TodoHandler) GetTodo(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return apperror.Unauthorized("Authentication required")
+	}
+
 	// Get ID from URL parameter
 	id := c.Param("id")
 	if id == "" {
-		return utils.ValidationErrorResponse(c, "ID is required")
+		return apperror.Validation("ID is required")
 	}
-	
+
 	// Get todo via service
-	todo, err := h.todoService.GetTodoByID(c.Request().Context(), id)
+	todo, err := h.todoService.GetTodoByID(c.Request().Context(), userID, id)
 	if err != nil {
-		return utils.InternalErrorResponse(c, "Failed to get todo")
-	}
-	
-	// Check if todo was found
-	if todo == nil {
-		return utils.NotFoundResponse(c, "Todo not found")
+		return err
 	}
-	
+
+	setETag(c, todo)
 	return utils.SuccessResponse(c, http.StatusOK, "Todo retrieved successfully", todo)
 }
 
-// GetAllTodos retrieves all todos
+// GetAllTodos retrieves a page of todos
 // @Summary Get all TODOs
-// @Description Get all TODO items
+// @Description Get TODO items one page at a time, optionally filtered and sorted. Pass next_cursor from the previous response as cursor, together with the same filters and sort, to get the next page.
 // @Tags todos
 // @Produce json
+// @Param limit query int false "Maximum number of TODOs to return (1-100, default 20)"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param completed query bool false "Only return completed (true) or open (false) TODOs"
+// @Param created_after query string false "Only return TODOs created after this RFC 3339 time"
+// @Param created_before query string false "Only return TODOs created before this RFC 3339 time"
+// @Param updated_since query string false "Only return TODOs updated at or after this RFC 3339 time"
+// @Param sort query string false "Sort order, prefix with - for descending" Enums(created_at, -created_at, updated_at, -updated_at, title, -title)
 // @Success 200 {object} utils.Response{data=[]models.Todo} "Successfully retrieved"
+// @Failure 400 {object} utils.Response "Bad request"
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Failure 403 {object} utils.Response "API key lacks the required scope"
 // @Failure 500 {object} utils.Response "Internal server error"
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /api/v1/todos [get]
 func (h *TodoHandler) GetAllTodos(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return apperror.Unauthorized("Authentication required")
+	}
+
+	opts, err := parseListOptions(c)
+	if err != nil {
+		return apperror.Validation(err.Error())
+	}
 
-	todos,err := h.todoService.GetAllTodos(c.Request().Context())
+	page, err := h.todoService.GetAllTodos(c.Request().Context(), userID, opts)
 	if err != nil {
-		return utils.InternalErrorResponse(c,"faild")
+		return err
+	}
+
+	return utils.PaginatedResponse(c, http.StatusOK, "Todos retrieved successfully", page.Todos, page.NextCursor)
+}
+
+// parseListOptions reads paging, filter and sort query parameters
+func parseListOptions(c echo.Context) (models.TodoListOptions, error) {
+	var opts models.TodoListOptions
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > models.MaxTodoListLimit {
+			return opts, errors.New("limit must be between 1 and " + strconv.Itoa(models.MaxTodoListLimit))
+		}
+		opts.Limit = n
+	}
+	opts.Cursor = c.QueryParam("cursor")
+
+	if completed := c.QueryParam("completed"); completed != "" {
+		b, err := strconv.ParseBool(completed)
+		if err != nil {
+			return opts, errors.New("completed must be true or false")
+		}
+		opts.Completed = &b
+	}
+
+	for _, p := range []struct {
+		name string
+		dest **time.Time
+	}{
+		{"created_after", &opts.CreatedAfter},
+		{"created_before", &opts.CreatedBefore},
+		{"updated_since", &opts.UpdatedSince},
+	} {
+		if value := c.QueryParam(p.name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return opts, errors.New(p.name + " must be an RFC 3339 time")
+			}
+			*p.dest = &t
+		}
+	}
+
+	if sort := c.QueryParam("sort"); sort != "" {
+		opts.SortDesc = strings.HasPrefix(sort, "-")
+		opts.SortBy = models.TodoSortField(strings.TrimPrefix(sort, "-"))
+		switch opts.SortBy {
+		case models.TodoSortCreatedAt, models.TodoSortUpdatedAt, models.TodoSortTitle:
+		default:
+			return opts, errors.New("sort must be one of created_at, updated_at, title, optionally prefixed with -")
+		}
 	}
 
-	return utils.SuccessResponse(c,http.StatusOK,"OK",todos)
+	return opts, nil
 }
 
-// UpdateTodo updates an existing todo
-// @Summary Update a TODO
-// @Description Update an existing TODO item
+// SearchTodos finds todos by keyword
+// @Summary Search TODOs
+// @Description Full-text search over TODO titles and descriptions. Every word must match, the last characters of a word may be omitted (prefix match). Results are ranked by relevance and include HTML escaped highlights with matches wrapped in <mark> tags. The search index is kept in memory by each server process, so changes made through another instance may be missing until the index is reloaded.
+// @Tags todos
+// @Produce json
+// @Param q query string true "Search query"
+// @Param limit query int false "Maximum number of results (1-100, default 20)"
+// @Success 200 {object} utils.Response{data=[]models.TodoSearchResult} "Successfully searched"
+// @Failure 400 {object} utils.Response "Bad request"
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Failure 403 {object} utils.Response "API key lacks the required scope"
+// @Failure 500 {object} utils.Response "Internal server error"
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/todos/search [get]
+func (h *TodoHandler) SearchTodos(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return apperror.Unauthorized("Authentication required")
+	}
+
+	query := c.QueryParam("q")
+	if query == "" {
+		return apperror.Validation("q is required")
+	}
+
+	var limit int
+	if value := c.QueryParam("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > models.MaxTodoListLimit {
+			return apperror.Validation("limit must be between 1 and " + strconv.Itoa(models.MaxTodoListLimit))
+		}
+		limit = n
+	}
+
+	results, err := h.todoService.SearchTodos(c.Request().Context(), userID, query, limit)
+	if err != nil {
+		return err
+	}
+
+	return utils.SuccessResponse(c, http.StatusOK, "Todos searched successfully", results)
+}
+
+// UpdateTodo replaces an existing todo
+// @Summary Replace a TODO
+// @Description Replace the title, description and completed fields of an existing TODO item, fields left out are cleared. Use PATCH to change single fields. Send the ETag of the TODO as If-Match to only update it if it has not been modified since.
 // @Tags todos
 // @Accept json
 // @Produce json
 // @Param id path string true "TODO ID"
+// @Param If-Match header string false "ETag of the TODO to update"
 // @Param todo body models.UpdateTodoRequest true "Update TODO request"
 // @Success 200 {object} utils.Response{data=models.Todo} "Successfully updated"
+// @Header 200 {string} ETag "New version of the TODO"
 // @Failure 400 {object} utils.Response "Bad request"
 // @Failure 404 {object} utils.Response "TODO not found"
+// @Failure 409 {object} utils.Response "TODO was modified concurrently"
+// @Failure 412 {object} utils.Response "If-Match does not match the current ETag"
+// @Failure 413 {object} utils.Response "Request body too large"
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Failure 403 {object} utils.Response "API key lacks the required scope"
 // @Failure 500 {object} utils.Response "Internal server error"
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /api/v1/todos/{id} [put]
 func (h *TodoHandler) UpdateTodo(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return apperror.Unauthorized("Authentication required")
+	}
+
 	// Get ID from URL parameter
 	id := c.Param("id")
 	if id == "" {
-		return utils.ValidationErrorResponse(c, "ID is required")
+		return apperror.Validation("ID is required")
+	}
+
+	version, err := h.ifMatchVersion(c, userID, id)
+	if err != nil {
+		return err
 	}
-	
+
 	var req models.UpdateTodoRequest
-	
+
 	// Bind request body
 	if err := c.Bind(&req); err != nil {
-		return utils.ValidationErrorResponse(c, "Invalid request format")
+		return err
 	}
-	
-	// Validate that at least one field is provided
-	if req.Title == nil && req.Description == nil && req.Completed == nil {
-		return utils.ValidationErrorResponse(c, "At least one field must be provided for update")
+
+	// Normalize and validate request
+	req.Normalize()
+	if err := utils.ValidateRequest(c, &req); err != nil {
+		return apperror.Invalid(err)
 	}
-	
+
 	// Update todo via service
-	todo, err := h.todoService.UpdateTodo(c.Request().Context(), id, &req)
+	todo, err := h.todoService.UpdateTodo(c.Request().Context(), userID, id, &req, version)
 	if err != nil {
-		return utils.InternalErrorResponse(c, "Failed to update todo")
+		return writeError(c, err)
 	}
-	
-	// Check if todo was found
-	if todo == nil {
-		return utils.NotFoundResponse(c, "Todo not found")
-	}
-	
+
+	setETag(c, todo)
 	return utils.SuccessResponse(c, http.StatusOK, "Todo updated successfully", todo)
 }
 
+// acceptPatch lists the media types PatchTodo accepts
+var acceptPatch = utils.MIMEApplicationMergePatchJSON + ", " + utils.MIMEApplicationJSONPatchJSON
+
+// PatchTodo partially updates an existing todo
+// @Summary Patch a TODO
+// @Description Apply a JSON Merge Patch (application/merge-patch+json, RFC 7396) or a JSON Patch (application/json-patch+json, RFC 6902) to the document {"title", "description", "completed"} of a TODO item. The patched TODO is validated like a PUT request. JSON Patch operations are applied atomically: if any fails, including a test operation, the TODO is left unchanged. Send the ETag of the TODO as If-Match to only update it if it has not been modified since.
+// @Tags todos
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
+// @Produce json
+// @Param id path string true "TODO ID"
+// @Param If-Match header string false "ETag of the TODO to patch"
+// @Param patch body object true "Merge patch object or array of JSON Patch operations"
+// @Success 200 {object} utils.Response{data=models.Todo} "Successfully updated"
+// @Header 200 {string} ETag "New version of the TODO"
+// @Failure 400 {object} utils.Response "Bad request"
+// @Failure 404 {object} utils.Response "TODO not found"
+// @Failure 409 {object} utils.Response "A JSON Patch test failed, a path does not exist or the TODO was modified concurrently"
+// @Failure 412 {object} utils.Response "If-Match does not match the current ETag"
+// @Failure 413 {object} utils.Response "Request body too large"
+// @Failure 415 {object} utils.Response "Unsupported patch format"
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Failure 403 {object} utils.Response "API key lacks the required scope"
+// @Failure 500 {object} utils.Response "Internal server error"
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/todos/{id} [patch]
+func (h *TodoHandler) PatchTodo(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return apperror.Unauthorized("Authentication required")
+	}
+
+	// Get ID from URL parameter
+	id := c.Param("id")
+	if id == "" {
+		return apperror.Validation("ID is required")
+	}
+
+	version, err := h.ifMatchVersion(c, userID, id)
+	if err != nil {
+		return err
+	}
+
+	// Pick the patch format by Content-Type
+	var apply func(doc, patch []byte) ([]byte, error)
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	switch mediaType {
+	case utils.MIMEApplicationMergePatchJSON:
+		apply = utils.ApplyMergePatch
+	case utils.MIMEApplicationJSONPatchJSON:
+		apply = utils.ApplyJSONPatch
+	default:
+		c.Response().Header().Set("Accept-Patch", acceptPatch)
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "Content-Type must be one of "+acceptPatch)
+	}
+
+	patch, err := utils.ReadJSONBody(c)
+	if err != nil {
+		return err
+	}
+	// A merge patch that is not an object would replace the whole todo
+	if mediaType == utils.MIMEApplicationMergePatchJSON && json.Valid(patch) && !utils.IsJSONObject(patch) {
+		return apperror.Validation("A merge patch must be a JSON object")
+	}
+
+	// Patch todo via service, the patched fields are validated like UpdateTodo
+	todo, err := h.todoService.PatchTodo(c.Request().Context(), userID, id, func(current *models.UpdateTodoRequest) error {
+		doc, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+		if doc, err = apply(doc, patch); err != nil {
+			return patchError(err)
+		}
+		if !utils.IsJSONObject(doc) {
+			return apperror.Validation("The patched todo must be a JSON object")
+		}
+
+		var req models.UpdateTodoRequest
+		if err := utils.DecodeJSON(c, doc, &req, true); err != nil {
+			return err
+		}
+		req.Normalize()
+		if err := utils.ValidateRequest(c, &req); err != nil {
+			return apperror.Invalid(err)
+		}
+
+		*current = req
+		return nil
+	}, version)
+	if err != nil {
+		return writeError(c, err)
+	}
+
+	setETag(c, todo)
+	return utils.SuccessResponse(c, http.StatusOK, "Todo patched successfully", todo)
+}
+
+// patchError maps the errors of applying a patch document: malformed
+// patches are invalid requests, patches that do not match the todo conflict
+// with it
+func patchError(err error) error {
+	switch {
+	case errors.Is(err, utils.ErrInvalidPatch):
+		return apperror.Validation(err.Error())
+	case errors.Is(err, utils.ErrPatchConflict):
+		return apperror.Conflict(err.Error())
+	default:
+		return err
+	}
+}
+
 // DeleteTodo deletes a todo by ID
 // @Summary Delete a TODO
-// @Description Delete a TODO item by ID
+// @Description Delete a TODO item by ID. Send the ETag of the TODO as If-Match to only delete it if it has not been modified since.
 // @Tags todos
 // @Produce json
 // @Param id path string true "TODO ID"
+// @Param If-Match header string false "ETag of the TODO to delete"
 // @Success 200 {object} utils.Response "Successfully deleted"
 // @Failure 400 {object} utils.Response "Bad request"
 // @Failure 404 {object} utils.Response "TODO not found"
+// @Failure 409 {object} utils.Response "TODO was modified concurrently"
+// @Failure 412 {object} utils.Response "If-Match does not match the current ETag"
+// @Failure 401 {object} utils.Response "Unauthorized"
+// @Failure 403 {object} utils.Response "API key lacks the required scope"
 // @Failure 500 {object} utils.Response "Internal server error"
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /api/v1/todos/{id} [delete]
 func (h *TodoHandler) DeleteTodo(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return apperror.Unauthorized("Authentication required")
+	}
+
 	// Get ID from URL parameter
 	id := c.Param("id")
 	if id == "" {
-		return utils.ValidationErrorResponse(c, "ID is required")
+		return apperror.Validation("ID is required")
+	}
+
+	version, err := h.ifMatchVersion(c, userID, id)
+	if err != nil {
+		return err
 	}
-	
+
 	// Delete todo via service
-	err := h.todoService.DeleteTodo(c.Request().Context(), id)
+	err = h.todoService.DeleteTodo(c.Request().Context(), userID, id, version)
 	if err != nil {
-		// Check for specific error types
-		if err.Error() == "todo not found" {
-			return utils.NotFoundResponse(c, "Todo not found")
-		}
-		return utils.InternalErrorResponse(c, "Failed to delete todo")
+		return writeError(c, err)
 	}
-	
+
 	return utils.SuccessResponse(c, http.StatusOK, "Todo deleted successfully", nil)
-}
\ No newline at end of file
+}
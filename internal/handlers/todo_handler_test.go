@@ -0,0 +1,437 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"echo-todo/internal/auth"
+	appmiddleware "echo-todo/internal/middleware"
+	"echo-todo/internal/repository"
+	"echo-todo/internal/services"
+	"echo-todo/pkg/models"
+	"echo-todo/pkg/utils"
+)
+
+// testUserHeader names the user a test request is authenticated as
+const testUserHeader = "X-Test-User"
+
+// newTestServer mounts the todo routes like internal/app, with a memory
+// repository behind the service unless one is given
+func newTestServer(t *testing.T, todoService services.TodoService) *echo.Echo {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if todoService == nil {
+		todoService = services.NewTodoService(repository.NewMemoryTodoRepository(), logger)
+	}
+	h := NewTodoHandler(todoService, logger)
+
+	e := echo.New()
+	e.HTTPErrorHandler = appmiddleware.ErrorHandler(logger)
+	e.Binder = &utils.JSONBinder{DisallowUnknownFields: true}
+	todos := e.Group("/api/v1/todos", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if id := c.Request().Header.Get(testUserHeader); id != "" {
+				req := c.Request()
+				c.SetRequest(req.WithContext(auth.WithUser(req.Context(), &auth.User{ID: id})))
+			}
+			return next(c)
+		}
+	})
+	todos.POST("", h.CreateTodo)
+	todos.GET("", h.GetAllTodos)
+	todos.GET("/:id", h.GetTodo)
+	todos.PUT("/:id", h.UpdateTodo)
+	todos.PATCH("/:id", h.PatchTodo)
+	todos.DELETE("/:id", h.DeleteTodo)
+	return e
+}
+
+// serve sends a request as user alice with a JSON body, header overrides
+// the defaults and an empty value removes one
+func serve(e *echo.Echo, method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(testUserHeader, "alice")
+	if body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	for key, value := range header {
+		if value == "" {
+			req.Header.Del(key)
+		} else {
+			req.Header.Set(key, value)
+		}
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+type testResponse struct {
+	Success    bool            `json:"success"`
+	Data       json.RawMessage `json:"data"`
+	NextCursor string          `json:"next_cursor"`
+	Error      string          `json:"error"`
+}
+
+func decodeResponse(t *testing.T, rec *httptest.ResponseRecorder) testResponse {
+	t.Helper()
+	var res testResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("body %q is not a Response: %v", rec.Body.String(), err)
+	}
+	return res
+}
+
+func decodeTodo(t *testing.T, rec *httptest.ResponseRecorder) models.Todo {
+	t.Helper()
+	var todo models.Todo
+	if err := json.Unmarshal(decodeResponse(t, rec).Data, &todo); err != nil {
+		t.Fatalf("data of %q is not a todo: %v", rec.Body.String(), err)
+	}
+	return todo
+}
+
+// createTodo creates a todo of alice and returns it
+func createTodo(t *testing.T, e *echo.Echo, body string) models.Todo {
+	t.Helper()
+	rec := serve(e, http.MethodPost, "/api/v1/todos", body, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create %s: status = %d, body %s", body, rec.Code, rec.Body)
+	}
+	return decodeTodo(t, rec)
+}
+
+// assertStatus fails unless rec has the status code want and, for errors,
+// an error message containing message
+func assertStatus(t *testing.T, rec *httptest.ResponseRecorder, want int, message string) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d, body %s", rec.Code, want, rec.Body)
+	}
+	if message != "" {
+		if res := decodeResponse(t, rec); !strings.Contains(res.Error, message) {
+			t.Errorf("error = %q, want it to contain %q", res.Error, message)
+		}
+	}
+}
+
+func TestCreateTodo(t *testing.T) {
+	e := newTestServer(t, nil)
+
+	rec := serve(e, http.MethodPost, "/api/v1/todos", `{"title":"  Buy   milk ","description":"2 liters"}`, nil)
+	assertStatus(t, rec, http.StatusCreated, "")
+	if got := rec.Header().Get("ETag"); got != `"1"` {
+		t.Errorf("ETag = %q, want %q", got, `"1"`)
+	}
+	todo := decodeTodo(t, rec)
+	if todo.ID == "" || todo.OwnerID != "alice" || todo.Title != "Buy milk" || todo.Description != "2 liters" ||
+		todo.Completed || todo.Version != 1 {
+		t.Errorf("created todo = %+v", todo)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		header map[string]string
+		status int
+	}{
+		{"missing title", `{"description":"d"}`, nil, http.StatusBadRequest},
+		{"blank title", `{"title":"   "}`, nil, http.StatusBadRequest},
+		{"line break in title", `{"title":"a\nb"}`, nil, http.StatusBadRequest},
+		{"wrong type", `{"title":1}`, nil, http.StatusBadRequest},
+		{"unknown field", `{"title":"a","done":true}`, nil, http.StatusBadRequest},
+		{"malformed JSON", `{"title":"a"`, nil, http.StatusBadRequest},
+		{"trailing data", `{"title":"a"}}`, nil, http.StatusBadRequest},
+		{"unauthenticated", `{"title":"a"}`, map[string]string{testUserHeader: ""}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertStatus(t, serve(e, http.MethodPost, "/api/v1/todos", tt.body, tt.header), tt.status, "")
+		})
+	}
+}
+
+func TestGetTodo(t *testing.T) {
+	e := newTestServer(t, nil)
+	todo := createTodo(t, e, `{"title":"a"}`)
+
+	rec := serve(e, http.MethodGet, "/api/v1/todos/"+todo.ID, "", nil)
+	assertStatus(t, rec, http.StatusOK, "")
+	if got := rec.Header().Get("ETag"); got != `"1"` {
+		t.Errorf("ETag = %q, want %q", got, `"1"`)
+	}
+	if got := decodeTodo(t, rec); got.ID != todo.ID || got.Title != "a" {
+		t.Errorf("todo = %+v", got)
+	}
+
+	assertStatus(t, serve(e, http.MethodGet, "/api/v1/todos/missing", "", nil), http.StatusNotFound, "")
+	// Todos of other users do not exist for the caller
+	assertStatus(t, serve(e, http.MethodGet, "/api/v1/todos/"+todo.ID, "", map[string]string{testUserHeader: "bob"}),
+		http.StatusNotFound, "")
+}
+
+func TestGetAllTodos(t *testing.T) {
+	e := newTestServer(t, nil)
+	var ids []string
+	for _, title := range []string{"c", "a", "b"} {
+		ids = append(ids, createTodo(t, e, `{"title":"`+title+`"}`).ID)
+	}
+	serve(e, http.MethodPatch, "/api/v1/todos/"+ids[1], `{"completed":true}`,
+		map[string]string{echo.HeaderContentType: utils.MIMEApplicationMergePatchJSON})
+	createTodo(t, e, `{"title":"other"}`)
+	serve(e, http.MethodPost, "/api/v1/todos", `{"title":"bob's"}`, map[string]string{testUserHeader: "bob"})
+
+	titles := func(rec *httptest.ResponseRecorder) []string {
+		var todos []models.Todo
+		if err := json.Unmarshal(decodeResponse(t, rec).Data, &todos); err != nil {
+			t.Fatalf("data of %q is not a list: %v", rec.Body.String(), err)
+		}
+		list := []string{}
+		for _, todo := range todos {
+			list = append(list, todo.Title)
+		}
+		return list
+	}
+
+	// Pages follow next_cursor until it is empty
+	var got []string
+	target := "/api/v1/todos?limit=2&sort=title"
+	for pages := 0; ; pages++ {
+		if pages == 3 {
+			t.Fatal("cursor does not end")
+		}
+		rec := serve(e, http.MethodGet, target, "", nil)
+		assertStatus(t, rec, http.StatusOK, "")
+		got = append(got, titles(rec)...)
+		res := decodeResponse(t, rec)
+		if res.NextCursor == "" {
+			break
+		}
+		target = "/api/v1/todos?limit=2&sort=title&cursor=" + res.NextCursor
+	}
+	if strings.Join(got, ",") != "a,b,c,other" {
+		t.Errorf("pages = %v, want alice's todos by title", got)
+	}
+
+	rec := serve(e, http.MethodGet, "/api/v1/todos?completed=false&sort=-title", "", nil)
+	assertStatus(t, rec, http.StatusOK, "")
+	if got := strings.Join(titles(rec), ","); got != "other,c,b" {
+		t.Errorf("open todos = %s, want other,c,b", got)
+	}
+
+	first := decodeResponse(t, serve(e, http.MethodGet, "/api/v1/todos?limit=1&sort=title", "", nil))
+	for _, query := range []string{
+		"limit=0",
+		"limit=x",
+		"completed=maybe",
+		"created_after=yesterday",
+		"sort=owner_id",
+		"cursor=garbage",
+		// A cursor only continues the listing it was issued for
+		"limit=1&sort=-title&cursor=" + first.NextCursor,
+	} {
+		t.Run(query, func(t *testing.T) {
+			assertStatus(t, serve(e, http.MethodGet, "/api/v1/todos?"+query, "", nil), http.StatusBadRequest, "")
+		})
+	}
+}
+
+func TestUpdateTodo(t *testing.T) {
+	e := newTestServer(t, nil)
+	todo := createTodo(t, e, `{"title":"a","description":"d"}`)
+	path := "/api/v1/todos/" + todo.ID
+
+	// PUT replaces the todo, omitted fields are cleared
+	rec := serve(e, http.MethodPut, path, `{"title":"b","completed":true}`, map[string]string{"If-Match": `"1"`})
+	assertStatus(t, rec, http.StatusOK, "")
+	if got := rec.Header().Get("ETag"); got != `"2"` {
+		t.Errorf("ETag = %q, want %q", got, `"2"`)
+	}
+	if got := decodeTodo(t, rec); got.Title != "b" || got.Description != "" || !got.Completed || got.Version != 2 {
+		t.Errorf("updated todo = %+v", got)
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		ifMatch string
+		status  int
+	}{
+		{"stale If-Match", `{"title":"c"}`, `"1"`, http.StatusPreconditionFailed},
+		{"weak If-Match", `{"title":"c"}`, `W/"2"`, http.StatusPreconditionFailed},
+		{"stale list", `{"title":"c"}`, `"1", W/"2", "3"`, http.StatusPreconditionFailed},
+		{"malformed If-Match", `{"title":"c"}`, `"2`, http.StatusPreconditionFailed},
+		{"star in list", `{"title":"c"}`, `*, "2"`, http.StatusPreconditionFailed},
+		{"unquoted", `{"title":"c"}`, `2`, http.StatusPreconditionFailed},
+		{"invalid body", `{"title":""}`, `"2"`, http.StatusBadRequest},
+		// Lists match if any tag is the current version
+		{"list", `{"title":"c"}`, `"1", "2"`, http.StatusOK},
+		{"star", `{"title":"d"}`, `*`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertStatus(t, serve(e, http.MethodPut, path, tt.body, map[string]string{"If-Match": tt.ifMatch}), tt.status, "")
+		})
+	}
+
+	// Without If-Match the update is unconditional
+	assertStatus(t, serve(e, http.MethodPut, path, `{"title":"c"}`, nil), http.StatusOK, "")
+	assertStatus(t, serve(e, http.MethodPut, "/api/v1/todos/missing", `{"title":"c"}`, nil), http.StatusNotFound, "")
+}
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		want    []int
+		wantErr bool
+	}{
+		{"absent", nil, nil, false},
+		{"star", []string{"*"}, nil, false},
+		{"single", []string{`"3"`}, []int{3}, false},
+		{"list", []string{`"1", "2" ,"3"`}, []int{1, 2, 3}, false},
+		{"header lines", []string{`"1"`, `"2"`}, []int{1, 2}, false},
+		{"weak tags never match", []string{`W/"1", "2"`}, []int{2}, false},
+		{"tags of no version", []string{`"abc", "0", "a,b"`}, []int{}, false},
+		{"empty elements", []string{`, "1",,`}, []int{1}, false},
+		{"unquoted", []string{`1`}, nil, true},
+		{"unterminated", []string{`"1", "2`}, nil, true},
+		{"missing comma", []string{`"1" "2"`}, nil, true},
+		{"star in list", []string{`*, "1"`}, nil, true},
+		{"weak prefix only", []string{`W/`}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/", nil)
+			for _, header := range tt.headers {
+				req.Header.Add("If-Match", header)
+			}
+			got, err := parseIfMatch(echo.New().NewContext(req, httptest.NewRecorder()))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseIfMatch() error = %v, want error %t", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) || (got == nil) != (tt.want == nil) {
+				t.Errorf("parseIfMatch() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchTodo(t *testing.T) {
+	e := newTestServer(t, nil)
+	todo := createTodo(t, e, `{"title":"a","description":"d"}`)
+	path := "/api/v1/todos/" + todo.ID
+	mergePatch := map[string]string{echo.HeaderContentType: utils.MIMEApplicationMergePatchJSON}
+	jsonPatch := map[string]string{echo.HeaderContentType: utils.MIMEApplicationJSONPatchJSON}
+
+	rec := serve(e, http.MethodPatch, path, `{"completed":true,"description":null}`, mergePatch)
+	assertStatus(t, rec, http.StatusOK, "")
+	if got := decodeTodo(t, rec); got.Title != "a" || got.Description != "" || !got.Completed || got.Version != 2 {
+		t.Errorf("merge patched todo = %+v", got)
+	}
+
+	rec = serve(e, http.MethodPatch, path,
+		`[{"op":"test","path":"/title","value":"a"},{"op":"replace","path":"/title","value":"b"}]`, jsonPatch)
+	assertStatus(t, rec, http.StatusOK, "")
+	if got := decodeTodo(t, rec); got.Title != "b" || !got.Completed || got.Version != 3 {
+		t.Errorf("JSON patched todo = %+v", got)
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		header  map[string]string
+		status  int
+		message string
+	}{
+		{"non-object merge patch", `"x"`, mergePatch, http.StatusBadRequest, "A merge patch must be a JSON object"},
+		{"null merge patch", `null`, mergePatch, http.StatusBadRequest, "A merge patch must be a JSON object"},
+		{"invalid patched todo", `{"title":""}`, mergePatch, http.StatusBadRequest, ""},
+		{"unknown member", `{"owner_id":"bob"}`, mergePatch, http.StatusBadRequest, ""},
+		{"failed test", `[{"op":"test","path":"/title","value":"a"}]`, jsonPatch, http.StatusConflict, ""},
+		{"missing path", `[{"op":"remove","path":"/tags"}]`, jsonPatch, http.StatusConflict, ""},
+		{"malformed JSON patch", `{"op":"remove"}`, jsonPatch, http.StatusBadRequest, ""},
+		{"root replaced by array", `[{"op":"replace","path":"","value":[1]}]`, jsonPatch, http.StatusBadRequest, "The patched todo must be a JSON object"},
+		{"stale If-Match", `{"title":"c"}`, map[string]string{echo.HeaderContentType: utils.MIMEApplicationMergePatchJSON, "If-Match": `"1"`},
+			http.StatusPreconditionFailed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertStatus(t, serve(e, http.MethodPatch, path, tt.body, tt.header), tt.status, tt.message)
+		})
+	}
+
+	rec = serve(e, http.MethodPatch, path, `{"title":"c"}`, nil)
+	assertStatus(t, rec, http.StatusUnsupportedMediaType, "")
+	if rec.Header().Get("Accept-Patch") == "" {
+		t.Error("415 response lacks Accept-Patch")
+	}
+
+	// Failed patches leave the todo unchanged
+	if got := decodeTodo(t, serve(e, http.MethodGet, path, "", nil)); got.Title != "b" || got.Version != 3 {
+		t.Errorf("todo after failed patches = %+v", got)
+	}
+}
+
+func TestDeleteTodo(t *testing.T) {
+	e := newTestServer(t, nil)
+	todo := createTodo(t, e, `{"title":"a"}`)
+	path := "/api/v1/todos/" + todo.ID
+
+	assertStatus(t, serve(e, http.MethodDelete, path, "", map[string]string{"If-Match": `"2"`}), http.StatusPreconditionFailed, "")
+	assertStatus(t, serve(e, http.MethodDelete, path, "", map[string]string{"If-Match": `"1"`}), http.StatusOK, "")
+	assertStatus(t, serve(e, http.MethodDelete, path, "", nil), http.StatusNotFound, "")
+
+	// Writes after the delete do not bring the todo back
+	assertStatus(t, serve(e, http.MethodPut, path, `{"title":"b"}`, nil), http.StatusNotFound, "")
+	assertStatus(t, serve(e, http.MethodPatch, path, `{"title":"b"}`,
+		map[string]string{echo.HeaderContentType: utils.MIMEApplicationMergePatchJSON}), http.StatusNotFound, "")
+	assertStatus(t, serve(e, http.MethodGet, path, "", nil), http.StatusNotFound, "")
+}
+
+// conflictingTodoService loses every write against a concurrent update
+type conflictingTodoService struct {
+	services.TodoService
+}
+
+func (conflictingTodoService) UpdateTodo(ctx context.Context, ownerID, id string, req *models.UpdateTodoRequest, version int) (*models.Todo, error) {
+	return nil, services.ErrVersionConflict
+}
+
+func (conflictingTodoService) DeleteTodo(ctx context.Context, ownerID, id string, version int) error {
+	return services.ErrVersionConflict
+}
+
+// TestVersionConflict checks that a lost write is a 412 for callers that
+// sent If-Match and a 409 for the others
+func TestVersionConflict(t *testing.T) {
+	e := newTestServer(t, conflictingTodoService{})
+
+	tests := []struct {
+		name    string
+		method  string
+		body    string
+		ifMatch string
+		status  int
+	}{
+		{"update with If-Match", http.MethodPut, `{"title":"a"}`, `"1"`, http.StatusPreconditionFailed},
+		{"update without If-Match", http.MethodPut, `{"title":"a"}`, "", http.StatusConflict},
+		{"delete with If-Match", http.MethodDelete, "", `"1"`, http.StatusPreconditionFailed},
+		{"delete without If-Match", http.MethodDelete, "", "", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(e, tt.method, "/api/v1/todos/1", tt.body, map[string]string{"If-Match": tt.ifMatch})
+			assertStatus(t, rec, tt.status, "")
+		})
+	}
+}
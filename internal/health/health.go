@@ -0,0 +1,146 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Check statuses
+const (
+	StatusPass = "pass"
+	StatusFail = "fail"
+)
+
+// Check is a named readiness check. Run returns nil when the dependency it
+// covers can serve traffic.
+type Check struct {
+	Name string
+	// Timeout bounds a single run, the default timeout of the Checker when zero
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of the last run of a check. Only the name and
+// status are sent to clients, the error may reveal details of dependencies
+// and is for the server log.
+type CheckResult struct {
+	Name      string        `json:"name"`
+	Status    string        `json:"status"`
+	Error     string        `json:"-"`
+	Latency   time.Duration `json:"-"`
+	CheckedAt time.Time     `json:"-"`
+}
+
+// Report is the outcome of every check. Status is StatusFail when any check
+// failed.
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// Checker runs readiness checks concurrently and caches their results so
+// frequent probes do not hammer the dependencies.
+type Checker struct {
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	mu      sync.Mutex
+	entries []*entry
+}
+
+// entry holds a check and its cached result. mu is held while the check runs
+// so concurrent probes wait for a single run.
+type entry struct {
+	check Check
+
+	mu     sync.Mutex
+	result CheckResult
+	valid  bool
+}
+
+// NewChecker creates a Checker running each check for at most timeout unless
+// the check sets its own, and reusing results for cacheTTL
+func NewChecker(timeout, cacheTTL time.Duration) *Checker {
+	return &Checker{
+		timeout:  timeout,
+		cacheTTL: cacheTTL,
+	}
+}
+
+// Add registers a check
+func (c *Checker) Add(check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = append(c.entries, &entry{check: check})
+}
+
+// Check runs the registered checks whose cached result expired and reports
+// the result of every check in registration order
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.Lock()
+	entries := append([]*entry(nil), c.entries...)
+	c.mu.Unlock()
+
+	report := Report{Status: StatusPass, Checks: make([]CheckResult, len(entries))}
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = c.run(ctx, e)
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != StatusPass {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, e *entry) CheckResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.valid && time.Since(e.result.CheckedAt) < c.cacheTTL {
+		return e.result
+	}
+
+	timeout := e.check.Timeout
+	if timeout <= 0 {
+		timeout = c.timeout
+	}
+	// The result is shared between probes, so do not let a single
+	// cancelled probe fail the check
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	// Give up on checks ignoring their context once the timeout expires
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- e.check.Run(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+
+	result := CheckResult{
+		Name:      e.check.Name,
+		Status:    StatusPass,
+		Latency:   time.Since(start),
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	e.result = result
+	e.valid = true
+	return result
+}
@@ -0,0 +1,188 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingCheck returns a check counting its runs and failing with err
+func countingCheck(name string, runs *atomic.Int32, err error) Check {
+	return Check{Name: name, Run: func(context.Context) error {
+		runs.Add(1)
+		return err
+	}}
+}
+
+func TestCheckerReport(t *testing.T) {
+	checker := NewChecker(time.Second, 0)
+	var runs atomic.Int32
+	checker.Add(countingCheck("a", &runs, nil))
+	checker.Add(countingCheck("b", &runs, errors.New("dial tcp 10.0.0.1:5432: connection refused")))
+	checker.Add(countingCheck("c", &runs, nil))
+
+	report := checker.Check(context.Background())
+	if report.Status != StatusFail {
+		t.Errorf("Status = %s, want fail", report.Status)
+	}
+	var statuses []string
+	for _, result := range report.Checks {
+		statuses = append(statuses, result.Name+"="+result.Status)
+	}
+	if got := strings.Join(statuses, " "); got != "a=pass b=fail c=pass" {
+		t.Errorf("checks = %s", got)
+	}
+	if report.Checks[1].Error == "" {
+		t.Error("error of failed check is not kept for the log")
+	}
+
+	// Clients only see the name and status
+	body, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "10.0.0.1") {
+		t.Errorf("report leaks the error: %s", body)
+	}
+	var decoded struct {
+		Checks []map[string]interface{} `json:"checks"`
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	for _, check := range decoded.Checks {
+		if len(check) != 2 || check["name"] == nil || check["status"] == nil {
+			t.Errorf("check in report = %v, want only name and status", check)
+		}
+	}
+}
+
+func TestCheckerCache(t *testing.T) {
+	t.Run("within ttl", func(t *testing.T) {
+		checker := NewChecker(time.Second, time.Hour)
+		var runs atomic.Int32
+		checker.Add(countingCheck("a", &runs, nil))
+		for i := 0; i < 3; i++ {
+			checker.Check(context.Background())
+		}
+		if n := runs.Load(); n != 1 {
+			t.Errorf("check ran %d times, want 1", n)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		checker := NewChecker(time.Second, 0)
+		var runs atomic.Int32
+		checker.Add(countingCheck("a", &runs, nil))
+		for i := 0; i < 3; i++ {
+			checker.Check(context.Background())
+		}
+		if n := runs.Load(); n != 3 {
+			t.Errorf("check ran %d times, want 3", n)
+		}
+	})
+
+	t.Run("failures are cached", func(t *testing.T) {
+		checker := NewChecker(time.Second, time.Hour)
+		var runs atomic.Int32
+		checker.Add(countingCheck("a", &runs, errors.New("down")))
+		for i := 0; i < 3; i++ {
+			if report := checker.Check(context.Background()); report.Status != StatusFail {
+				t.Fatalf("probe %d: Status = %s, want fail", i, report.Status)
+			}
+		}
+		if n := runs.Load(); n != 1 {
+			t.Errorf("check ran %d times, want 1", n)
+		}
+	})
+
+	t.Run("concurrent probes share a run", func(t *testing.T) {
+		checker := NewChecker(time.Second, time.Hour)
+		var runs atomic.Int32
+		release := make(chan struct{})
+		checker.Add(Check{Name: "slow", Run: func(context.Context) error {
+			runs.Add(1)
+			<-release
+			return nil
+		}})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				checker.Check(context.Background())
+			}()
+		}
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		if n := runs.Load(); n != 1 {
+			t.Errorf("check ran %d times, want 1", n)
+		}
+	})
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	block := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	// Checks ignoring their context are given up on too
+	ignore := func(context.Context) error {
+		time.Sleep(300 * time.Millisecond)
+		return nil
+	}
+
+	checker := NewChecker(20*time.Millisecond, 0)
+	checker.Add(Check{Name: "default timeout", Run: block})
+	checker.Add(Check{Name: "ignores context", Run: ignore})
+	checker.Add(Check{Name: "own timeout", Timeout: 100 * time.Millisecond, Run: func(ctx context.Context) error {
+		select {
+		case <-time.After(50 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}})
+
+	start := time.Now()
+	report := checker.Check(context.Background())
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("Check took %s", elapsed)
+	}
+	want := []string{StatusFail, StatusFail, StatusPass}
+	for i, result := range report.Checks {
+		if result.Status != want[i] {
+			t.Errorf("%s: Status = %s (%s), want %s", result.Name, result.Status, result.Error, want[i])
+		}
+	}
+	if !strings.Contains(report.Checks[1].Error, "timed out") {
+		t.Errorf("error = %q, want a timeout", report.Checks[1].Error)
+	}
+}
+
+// TestCheckerCancelledProbe checks a probe giving up does not fail the
+// shared result
+func TestCheckerCancelledProbe(t *testing.T) {
+	checker := NewChecker(time.Second, time.Hour)
+	checker.Add(Check{Name: "slow", Run: func(ctx context.Context) error {
+		select {
+		case <-time.After(20 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if report := checker.Check(ctx); report.Status != StatusPass {
+		t.Errorf("Status = %s (%s), want pass", report.Status, report.Checks[0].Error)
+	}
+}
@@ -0,0 +1,259 @@
+package lambdaproxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ErrUnsupportedEvent is returned for payloads that are not HTTP events
+var ErrUnsupportedEvent = errors.New("unsupported lambda event, expected an API Gateway or Function URL event")
+
+// Handler serves the HTTP events of API Gateway REST APIs (payload format
+// 1.0), HTTP APIs (payload format 2.0) and Lambda Function URLs with an
+// http.Handler
+type Handler struct {
+	handler http.Handler
+}
+
+func NewHandler(handler http.Handler) *Handler {
+	return &Handler{handler: handler}
+}
+
+// Invoke serves a single event and returns the response in the format of
+// its source. It has the signature expected by lambda.Start.
+func (h *Handler) Invoke(ctx context.Context, event json.RawMessage) (interface{}, error) {
+	var probe struct {
+		Version        string `json:"version"`
+		HTTPMethod     string `json:"httpMethod"`
+		RequestContext struct {
+			DomainName string `json:"domainName"`
+		} `json:"requestContext"`
+	}
+	if err := json.Unmarshal(event, &probe); err != nil {
+		return nil, fmt.Errorf("decode lambda event: %w", err)
+	}
+
+	switch {
+	case probe.Version == "2.0":
+		var req events.APIGatewayV2HTTPRequest
+		if err := json.Unmarshal(event, &req); err != nil {
+			return nil, fmt.Errorf("decode payload format 2.0 event: %w", err)
+		}
+		res, err := h.serveV2(ctx, &req)
+		if err != nil {
+			return nil, err
+		}
+		// Function URLs share the payload format of HTTP APIs and are only
+		// told apart by their domain
+		if !strings.Contains(probe.RequestContext.DomainName, ".lambda-url.") {
+			return res, nil
+		}
+		return &events.LambdaFunctionURLResponse{
+			StatusCode:      res.StatusCode,
+			Headers:         res.Headers,
+			Body:            res.Body,
+			IsBase64Encoded: res.IsBase64Encoded,
+			Cookies:         res.Cookies,
+		}, nil
+	case probe.HTTPMethod != "":
+		var req events.APIGatewayProxyRequest
+		if err := json.Unmarshal(event, &req); err != nil {
+			return nil, fmt.Errorf("decode payload format 1.0 event: %w", err)
+		}
+		return h.serveV1(ctx, &req)
+	default:
+		return nil, ErrUnsupportedEvent
+	}
+}
+
+func (h *Handler) serveV1(ctx context.Context, event *events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	header := make(http.Header)
+	for key, value := range event.Headers {
+		header.Set(key, value)
+	}
+	for key, values := range event.MultiValueHeaders {
+		header.Del(key)
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+
+	// Query parameters arrive decoded
+	query := make(url.Values)
+	for key, value := range event.QueryStringParameters {
+		query.Set(key, value)
+	}
+	for key, values := range event.MultiValueQueryStringParameters {
+		query[key] = values
+	}
+
+	req, err := newRequest(ctx, event.HTTPMethod, event.Path, query.Encode(), header,
+		event.Body, event.IsBase64Encoded, event.RequestContext.Identity.SourceIP, event.RequestContext.RequestID)
+	if err != nil {
+		return nil, err
+	}
+
+	w := newResponseWriter()
+	h.handler.ServeHTTP(w, req)
+
+	body, isBase64 := w.encodedBody()
+	return &events.APIGatewayProxyResponse{
+		StatusCode:        w.statusCode(),
+		MultiValueHeaders: w.header,
+		Body:              body,
+		IsBase64Encoded:   isBase64,
+	}, nil
+}
+
+func (h *Handler) serveV2(ctx context.Context, event *events.APIGatewayV2HTTPRequest) (*events.APIGatewayV2HTTPResponse, error) {
+	// Repeated headers arrive joined with commas
+	header := make(http.Header)
+	for key, value := range event.Headers {
+		header.Set(key, value)
+	}
+	if len(event.Cookies) > 0 {
+		header.Set("Cookie", strings.Join(event.Cookies, "; "))
+	}
+
+	// Paths of named stages start with the stage, the routes do not
+	path := event.RawPath
+	if stage := event.RequestContext.Stage; stage != "" && stage != "$default" {
+		prefix := "/" + stage
+		if path == prefix {
+			path = "/"
+		} else if strings.HasPrefix(path, prefix+"/") {
+			path = strings.TrimPrefix(path, prefix)
+		}
+	}
+
+	req, err := newRequest(ctx, event.RequestContext.HTTP.Method, path, event.RawQueryString, header,
+		event.Body, event.IsBase64Encoded, event.RequestContext.HTTP.SourceIP, event.RequestContext.RequestID)
+	if err != nil {
+		return nil, err
+	}
+
+	w := newResponseWriter()
+	h.handler.ServeHTTP(w, req)
+
+	res := &events.APIGatewayV2HTTPResponse{
+		StatusCode: w.statusCode(),
+		Headers:    make(map[string]string, len(w.header)),
+		Cookies:    w.header.Values("Set-Cookie"),
+	}
+	for key, values := range w.header {
+		if key != "Set-Cookie" {
+			res.Headers[key] = strings.Join(values, ",")
+		}
+	}
+	res.Body, res.IsBase64Encoded = w.encodedBody()
+	return res, nil
+}
+
+// newRequest builds the request handed to the router. The Lambda request id
+// becomes the X-Request-ID unless the client sent one, so logs can be
+// matched with those of API Gateway.
+func newRequest(ctx context.Context, method, path, rawQuery string, header http.Header,
+	body string, isBase64 bool, sourceIP, lambdaRequestID string) (*http.Request, error) {
+	var payload []byte
+	if isBase64 {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, fmt.Errorf("decode base64 request body: %w", err)
+		}
+		payload = decoded
+	} else {
+		payload = []byte(body)
+	}
+
+	u := &url.URL{Path: path, RawQuery: rawQuery}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	req.Host = header.Get("Host")
+	req.RequestURI = u.RequestURI()
+	req.RemoteAddr = net.JoinHostPort(sourceIP, "0")
+	if header.Get("X-Request-ID") == "" && lambdaRequestID != "" {
+		header.Set("X-Request-ID", lambdaRequestID)
+	}
+
+	return req, nil
+}
+
+// responseWriter buffers the response for the Lambda return value
+type responseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseWriter() *responseWriter {
+	return &responseWriter{header: make(http.Header)}
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.body.Write(b)
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Flush is a no-op, the response is sent once the handler returns
+func (w *responseWriter) Flush() {}
+
+func (w *responseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// encodedBody returns the body as is for text content and base64 encoded
+// otherwise, reporting which encoding was used
+func (w *responseWriter) encodedBody() (string, bool) {
+	if w.body.Len() == 0 || (w.header.Get("Content-Encoding") == "" && isText(w.header.Get("Content-Type"))) {
+		return w.body.String(), false
+	}
+	return base64.StdEncoding.EncodeToString(w.body.Bytes()), true
+}
+
+// isText reports whether the content type can be returned as a string
+func isText(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-www-form-urlencoded":
+		return true
+	}
+	return false
+}
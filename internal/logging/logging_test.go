@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"echo-todo/internal/auth"
+	"echo-todo/internal/repository"
+	"echo-todo/internal/requestid"
+	"echo-todo/internal/services"
+	"echo-todo/pkg/models"
+)
+
+// records decodes the JSON log lines written to buf
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		out = append(out, record)
+	}
+	return out
+}
+
+func TestContextHandler(t *testing.T) {
+	span := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1, 2, 3},
+		SpanID:  trace.SpanID{4, 5, 6},
+	})
+	full := requestid.WithID(context.Background(), "req-1")
+	full = auth.WithUser(full, &auth.User{ID: "alice"})
+	full = trace.ContextWithSpanContext(full, span)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want map[string]any
+	}{
+		{"request context", full, map[string]any{
+			"request_id": "req-1", "user_id": "alice",
+			"trace_id": span.TraceID().String(), "span_id": span.SpanID().String(),
+		}},
+		{"request id only", requestid.WithID(context.Background(), "req-2"), map[string]any{"request_id": "req-2"}},
+		{"no request", context.Background(), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := New(&buf, "info", FormatJSON)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Derived loggers keep adding the fields
+			logger.With("component", "test").InfoContext(tt.ctx, "message")
+
+			record := records(t, &buf)[0]
+			for _, key := range []string{"request_id", "user_id", "trace_id", "span_id"} {
+				if record[key] != tt.want[key] {
+					t.Errorf("%s = %v, want %v", key, record[key], tt.want[key])
+				}
+			}
+		})
+	}
+}
+
+// TestRequestScopedLogs checks the log lines of the service and repository
+// layers carry the request id of the context they are given
+func TestRequestScopedLogs(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "debug", FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := requestid.WithID(context.Background(), "req-1")
+
+	// Opening the repository logs the migrations it applies
+	repo, err := repository.NewSQLiteTodoRepository(ctx, filepath.Join(t.TempDir(), "todos.db"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	service := services.NewTodoService(repo, logger)
+	todo, err := service.CreateTodo(ctx, "alice", &models.CreateTodoRequest{Title: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.DeleteTodo(ctx, "alice", todo.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for _, record := range records(t, &buf) {
+		msg, _ := record["msg"].(string)
+		seen[msg] = true
+		if record["request_id"] != "req-1" {
+			t.Errorf("%q logged with request_id %v", msg, record["request_id"])
+		}
+	}
+	for _, msg := range []string{"applied migration", "created todo", "deleted todo"} {
+		if !seen[msg] {
+			t.Errorf("%q was not logged", msg)
+		}
+	}
+}
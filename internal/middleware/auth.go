@@ -1,43 +1,208 @@
 package middleware
 
 import (
-	"net/http"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
 
 	"github.com/labstack/echo/v4"
+
+	"echo-todo/internal/apperror"
+	"echo-todo/internal/auth"
+	"echo-todo/internal/services"
 )
 
-// BasicAuth provides basic authentication middleware
-func BasicAuth(username, password string) echo.MiddlewareFunc {
+// UserContextKey is the echo.Context key holding the authenticated *auth.User
+const UserContextKey = "user"
+
+// ErrInvalidCredentials is returned by an Authenticator for credentials it
+// understands but cannot verify
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrLockedOut is returned for a user locked out after repeated failures
+var ErrLockedOut = errors.New("too many failed login attempts")
+
+// HeaderAPIKey is the request header carrying an API key
+const HeaderAPIKey = "X-API-Key"
+
+// bearerChallenge is the WWW-Authenticate challenge of bearer token schemes
+const bearerChallenge = `Bearer realm="echo-todo"`
+
+// Authenticator verifies the credentials of one authentication scheme
+type Authenticator struct {
+	// Challenge is sent in WWW-Authenticate when a request is rejected
+	Challenge string
+	// Verify returns nil, nil when the request carries no credentials of
+	// the kind it handles
+	Verify func(c echo.Context) (*auth.User, error)
+}
+
+// Authenticate rejects requests not authenticated by any of authenticators
+// with 401 and stores the user on echo.Context and the request context.
+func Authenticate(logger *slog.Logger, authenticators ...Authenticator) echo.MiddlewareFunc {
+	var challenges []string
+	for _, a := range authenticators {
+		if a.Challenge != "" && !slices.Contains(challenges, a.Challenge) {
+			challenges = append(challenges, a.Challenge)
+		}
+	}
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			// TODO: Implement authentication logic if needed
-			return next(c)
+			var authErr error
+			for _, a := range authenticators {
+				user, err := a.Verify(c)
+				if err != nil {
+					if !errors.Is(err, ErrInvalidCredentials) && !errors.Is(err, ErrLockedOut) {
+						return fmt.Errorf("authenticate: %w", err)
+					}
+					authErr = err
+					continue
+				}
+				if user != nil {
+					c.Set(UserContextKey, user)
+					c.SetRequest(c.Request().WithContext(auth.WithUser(c.Request().Context(), user)))
+					return next(c)
+				}
+			}
+
+			if authErr != nil {
+				logger.DebugContext(c.Request().Context(), "rejected credentials", "error", authErr)
+			}
+			for _, challenge := range challenges {
+				c.Response().Header().Add(echo.HeaderWWWAuthenticate, challenge)
+			}
+			switch {
+			case errors.Is(authErr, ErrLockedOut):
+				return apperror.Unauthorized("Too many failed login attempts, try again later")
+			case authErr != nil:
+				return apperror.Unauthorized("Invalid credentials")
+			default:
+				return apperror.Unauthorized("Authentication required")
+			}
 		}
 	}
 }
 
-// RequestID adds a request ID to each request
-func RequestID() echo.MiddlewareFunc {
+// BearerToken authenticates "Authorization: Bearer <token>" against the
+// hashed tokens of the user store
+func BearerToken(store *auth.UserStore) Authenticator {
+	return Authenticator{
+		Challenge: bearerChallenge,
+		Verify: func(c echo.Context) (*auth.User, error) {
+			token, ok := bearerToken(c)
+			if !ok {
+				return nil, nil
+			}
+
+			user, ok := store.AuthenticateToken(token)
+			if !ok {
+				return nil, ErrInvalidCredentials
+			}
+			return user, nil
+		},
+	}
+}
+
+// JWT authenticates "Authorization: Bearer <jwt>" with a signed JWT whose
+// subject becomes the user. Bearer tokens that are not JWTs are left to the
+// other authenticators.
+func JWT(verifier *auth.JWTVerifier) Authenticator {
+	return Authenticator{
+		Challenge: bearerChallenge,
+		Verify: func(c echo.Context) (*auth.User, error) {
+			token, ok := bearerToken(c)
+			if !ok || strings.Count(token, ".") != 2 {
+				return nil, nil
+			}
+
+			user, err := verifier.Verify(token)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+			}
+			return user, nil
+		},
+	}
+}
+
+// APIKey authenticates API keys sent in the X-API-Key header or as bearer
+// token. The user is limited to the scopes of the key.
+func APIKey(apiKeys services.APIKeyService) Authenticator {
+	return Authenticator{
+		Verify: func(c echo.Context) (*auth.User, error) {
+			secret := c.Request().Header.Get(HeaderAPIKey)
+			if secret == "" {
+				token, ok := bearerToken(c)
+				if !ok || !strings.HasPrefix(token, services.APIKeyPrefix) {
+					return nil, nil
+				}
+				secret = token
+			}
+
+			key, err := apiKeys.AuthenticateAPIKey(c.Request().Context(), secret)
+			if errors.Is(err, services.ErrInvalidAPIKey) {
+				return nil, ErrInvalidCredentials
+			}
+			if err != nil {
+				return nil, err
+			}
+			return &auth.User{ID: key.OwnerID, Scopes: key.Scopes}, nil
+		},
+	}
+}
+
+// RequireScope rejects users lacking scope with 403. It must run after
+// Authenticate.
+func RequireScope(scope string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			// TODO: Implement request ID generation
+			user, ok := auth.UserFromContext(c.Request().Context())
+			if !ok {
+				return apperror.Unauthorized("Authentication required")
+			}
+			if !user.HasScope(scope) {
+				return apperror.Forbidden("Missing required scope " + scope)
+			}
 			return next(c)
 		}
 	}
 }
 
-// ErrorHandler provides custom error handling
-func ErrorHandler(err error, c echo.Context) {
-	code := http.StatusInternalServerError
-	message := "Internal Server Error"
-
-	if he, ok := err.(*echo.HTTPError); ok {
-		code = he.Code
-		message = he.Message.(string)
+// bearerToken extracts the token of a Bearer Authorization header
+func bearerToken(c echo.Context) (string, bool) {
+	scheme, token, ok := strings.Cut(c.Request().Header.Get(echo.HeaderAuthorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
 	}
+	return strings.TrimSpace(token), true
+}
+
+// BasicAuth authenticates "Authorization: Basic" credentials against the
+// password hashes of the user store. A user is locked out after repeated
+// failures, without checking the password, until the lockout expires.
+func BasicAuth(store *auth.UserStore, lockout *auth.Lockout) Authenticator {
+	return Authenticator{
+		Challenge: `Basic realm="echo-todo", charset="UTF-8"`,
+		Verify: func(c echo.Context) (*auth.User, error) {
+			username, password, ok := c.Request().BasicAuth()
+			if !ok {
+				return nil, nil
+			}
 
-	c.JSON(code, map[string]interface{}{
-		"error":   true,
-		"message": message,
-	})
-}
\ No newline at end of file
+			if lockout.Locked(username) {
+				return nil, ErrLockedOut
+			}
+
+			user, ok := store.AuthenticatePassword(username, password)
+			if !ok {
+				lockout.Fail(username)
+				return nil, ErrInvalidCredentials
+			}
+
+			lockout.Reset(username)
+			return user, nil
+		},
+	}
+}
@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+
+	"echo-todo/internal/auth"
+	"echo-todo/internal/repository"
+	"echo-todo/internal/services"
+	"echo-todo/pkg/models"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// newAuthServer serves GET / behind Authenticate with authenticators and
+// then middleware. The response body names the user and their scopes.
+func newAuthServer(authenticators []Authenticator, middleware ...echo.MiddlewareFunc) *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler(testLogger())
+	middleware = append([]echo.MiddlewareFunc{Authenticate(testLogger(), authenticators...)}, middleware...)
+	e.GET("/", func(c echo.Context) error {
+		user, _ := auth.UserFromContext(c.Request().Context())
+		if c.Get(UserContextKey) != user {
+			return c.String(http.StatusInternalServerError, "user differs between contexts")
+		}
+		scopes := "*"
+		if user.Scopes != nil {
+			scopes = strings.Join(user.Scopes, ",")
+		}
+		return c.String(http.StatusOK, user.ID+" "+scopes)
+	}, middleware...)
+	return e
+}
+
+func serve(e *echo.Echo, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for name, values := range header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func newTestAPIKey(t *testing.T, apiKeys services.APIKeyService, scopes ...string) string {
+	t.Helper()
+	key, err := apiKeys.CreateAPIKey(context.Background(), &auth.User{ID: "alice"}, &models.CreateAPIKeyRequest{Name: "test", Scopes: scopes})
+	if err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+	return key.Key
+}
+
+func TestAPIKey(t *testing.T) {
+	apiKeys := services.NewAPIKeyService(repository.NewMemoryAPIKeyRepository(), testLogger())
+	readKey := newTestAPIKey(t, apiKeys, models.ScopeTodosRead)
+	revokedKey := newTestAPIKey(t, apiKeys, models.ScopeTodosRead)
+	revoked, err := apiKeys.AuthenticateAPIKey(context.Background(), revokedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := apiKeys.RevokeAPIKey(context.Background(), &auth.User{ID: "alice"}, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bearer token store catches bearer tokens that are not API keys
+	store, err := auth.NewUserStore([]auth.UserEntry{{ID: "bob", TokenSHA256: auth.HashToken("bob-token")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newAuthServer([]Authenticator{APIKey(apiKeys), BearerToken(store)})
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		status   int
+		wantBody string
+	}{
+		{"header", http.Header{HeaderAPIKey: {readKey}}, http.StatusOK, "alice todos:read"},
+		{"bearer", http.Header{echo.HeaderAuthorization: {"Bearer " + readKey}}, http.StatusOK, "alice todos:read"},
+		{"other bearer token", http.Header{echo.HeaderAuthorization: {"Bearer bob-token"}}, http.StatusOK, "bob *"},
+		{"unknown key", http.Header{HeaderAPIKey: {services.APIKeyPrefix + "unknown"}}, http.StatusUnauthorized, "Invalid credentials"},
+		{"malformed key", http.Header{HeaderAPIKey: {"not-a-key"}}, http.StatusUnauthorized, "Invalid credentials"},
+		{"revoked key", http.Header{HeaderAPIKey: {revokedKey}}, http.StatusUnauthorized, "Invalid credentials"},
+		{"revoked bearer", http.Header{echo.HeaderAuthorization: {"Bearer " + revokedKey}}, http.StatusUnauthorized, "Invalid credentials"},
+		{"no credentials", nil, http.StatusUnauthorized, "Authentication required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(e, tt.header)
+			if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("got %d %s, want %d %q", rec.Code, rec.Body, tt.status, tt.wantBody)
+			}
+			if tt.status == http.StatusUnauthorized && rec.Header().Get(echo.HeaderWWWAuthenticate) != bearerChallenge {
+				t.Errorf("WWW-Authenticate = %q", rec.Header().Values(echo.HeaderWWWAuthenticate))
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	apiKeys := services.NewAPIKeyService(repository.NewMemoryAPIKeyRepository(), testLogger())
+	readKey := newTestAPIKey(t, apiKeys, models.ScopeTodosRead)
+	writeKey := newTestAPIKey(t, apiKeys, models.ScopeTodosRead, models.ScopeTodosWrite)
+	store, err := auth.NewUserStore([]auth.UserEntry{{ID: "bob", TokenSHA256: auth.HashToken("bob-token")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newAuthServer([]Authenticator{APIKey(apiKeys), BearerToken(store)}, RequireScope(models.ScopeTodosWrite))
+
+	tests := []struct {
+		name   string
+		header http.Header
+		status int
+	}{
+		{"key with scope", http.Header{HeaderAPIKey: {writeKey}}, http.StatusOK},
+		{"key without scope", http.Header{HeaderAPIKey: {readKey}}, http.StatusForbidden},
+		// Users of the store are not limited to scopes
+		{"user", http.Header{echo.HeaderAuthorization: {"Bearer bob-token"}}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(e, tt.header); rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+
+	// Without Authenticate there is no user
+	bare := echo.New()
+	bare.HTTPErrorHandler = ErrorHandler(testLogger())
+	bare.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, RequireScope(models.ScopeTodosRead))
+	if rec := serve(bare, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("status without Authenticate = %d, want 401", rec.Code)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("alice-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := auth.NewUserStore([]auth.UserEntry{{ID: "alice", PasswordHash: string(hash)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newAuthServer([]Authenticator{BasicAuth(store, auth.NewLockout(3, time.Minute))})
+
+	login := func(username, password string) *httptest.ResponseRecorder {
+		header := http.Header{}
+		header.Set(echo.HeaderAuthorization, "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+		return serve(e, header)
+	}
+	steps := []struct {
+		name     string
+		username string
+		password string
+		status   int
+		wantBody string
+	}{
+		{"correct password", "alice", "alice-password", http.StatusOK, "alice *"},
+		{"wrong password", "alice", "wrong", http.StatusUnauthorized, "Invalid credentials"},
+		{"second failure", "alice", "wrong", http.StatusUnauthorized, "Invalid credentials"},
+		// A success resets the count
+		{"success after failures", "alice", "alice-password", http.StatusOK, "alice *"},
+		{"failure 1", "alice", "wrong", http.StatusUnauthorized, "Invalid credentials"},
+		{"failure 2", "alice", "wrong", http.StatusUnauthorized, "Invalid credentials"},
+		{"failure 3", "alice", "wrong", http.StatusUnauthorized, "Invalid credentials"},
+		// The password is not checked while locked out
+		{"locked out", "alice", "alice-password", http.StatusUnauthorized, "Too many failed login attempts"},
+		{"unknown user", "mallory", "alice-password", http.StatusUnauthorized, "Invalid credentials"},
+	}
+	for _, step := range steps {
+		rec := login(step.username, step.password)
+		if rec.Code != step.status || !strings.Contains(rec.Body.String(), step.wantBody) {
+			t.Fatalf("%s: got %d %s, want %d %q", step.name, rec.Code, rec.Body, step.status, step.wantBody)
+		}
+		if rec.Code == http.StatusUnauthorized && !strings.HasPrefix(rec.Header().Get(echo.HeaderWWWAuthenticate), "Basic ") {
+			t.Errorf("%s: WWW-Authenticate = %q", step.name, rec.Header().Get(echo.HeaderWWWAuthenticate))
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"echo-todo/internal/apperror"
+	"echo-todo/pkg/utils"
+)
+
+// errorHandledContextKey marks a request whose error response is written
+const errorHandledContextKey = "error_handled"
+
+// ErrorHandler writes the response for errors returned by handlers and
+// middleware. Errors with an apperror kind and *echo.HTTPError keep their
+// status and message, anything else is logged and answered with a generic
+// 500 that does not leak the cause. Middleware writing the error response
+// early to record its status still return the error to the middleware
+// around them, so a request is only answered by the first call.
+func ErrorHandler(logger *slog.Logger) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		ctx := c.Request().Context()
+		if handled, _ := c.Get(errorHandledContextKey).(bool); handled {
+			return
+		}
+		c.Set(errorHandledContextKey, true)
+		if c.Response().Committed {
+			logger.WarnContext(ctx, "error after response was written", "error", err)
+			return
+		}
+
+		code, message := errorStatus(err)
+		var appErr *apperror.Error
+		switch {
+		case errors.As(err, &appErr) && appErr.Kind == apperror.KindUnavailable:
+			// The message is meant for clients, the cause is in the wrapped error
+			logger.WarnContext(ctx, message, "error", appErr.Err)
+		case code >= http.StatusInternalServerError:
+			logger.ErrorContext(ctx, "request failed", "error", err)
+		}
+
+		var fields utils.ValidationErrors
+		errors.As(err, &fields)
+		if c.Request().Method == http.MethodHead {
+			err = c.NoContent(code)
+		} else {
+			err = utils.FieldErrorResponse(c, code, message, fields)
+		}
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to write error response", "error", err)
+		}
+	}
+}
+
+// errorStatus returns the status code and client safe message for err
+func errorStatus(err error) (int, string) {
+	if kind := apperror.KindOf(err); kind != apperror.KindInternal {
+		return kind.Status(), err.Error()
+	}
+
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		if he.Code >= http.StatusInternalServerError {
+			return he.Code, http.StatusText(he.Code)
+		}
+		switch message := he.Message.(type) {
+		case string:
+			return he.Code, message
+		case error:
+			return he.Code, message.Error()
+		default:
+			return he.Code, http.StatusText(he.Code)
+		}
+	}
+
+	return http.StatusInternalServerError, "Internal server error"
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequestLogger logs one record per request with its route, status and
+// latency. The logger adds the request id and user from the request
+// context, so it must run after RequestID.
+func RequestLogger(logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				// Write the error response now so its status is logged
+				c.Error(err)
+			}
+
+			req := c.Request()
+			res := c.Response()
+			level := slog.LevelInfo
+			if res.Status >= 500 {
+				level = slog.LevelError
+			}
+
+			logger.LogAttrs(req.Context(), level, "request",
+				slog.String("method", req.Method),
+				slog.String("route", c.Path()),
+				slog.String("path", req.URL.Path),
+				slog.Int("status", res.Status),
+				slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.Int64("bytes_out", res.Size),
+				slog.String("remote_ip", c.RealIP()),
+			)
+			return err
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"echo-todo/internal/apperror"
+)
+
+// requestCount returns the value of the request counter with labels, or 0
+// when no request has been recorded with them
+func requestCount(t *testing.T, reg *prometheus.Registry, labels map[string]string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "echo_todo_http_requests_total" {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if labels[pair.GetName()] != pair.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	var logs bytes.Buffer
+	var returned error
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler(slog.New(slog.NewTextHandler(&logs, nil)))
+	// Catch the error Metrics hands on to the middleware around it
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			returned = next(c)
+			return returned
+		}
+	})
+	e.Use(Metrics(reg))
+	notFound := apperror.NotFound("todo not found")
+	e.GET("/todos/:id", func(c echo.Context) error {
+		if c.Param("id") == "missing" {
+			return notFound
+		}
+		return c.NoContent(http.StatusOK)
+	})
+
+	tests := []struct {
+		name    string
+		path    string
+		status  int
+		route   string
+		wantErr bool
+	}{
+		{"success", "/todos/1", http.StatusOK, "/todos/:id", false},
+		{"handler error", "/todos/missing", http.StatusNotFound, "/todos/:id", true},
+		{"unmatched route", "/unknown", http.StatusNotFound, unmatchedRoute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if (returned != nil) != tt.wantErr {
+				t.Errorf("returned error = %v, want error %t", returned, tt.wantErr)
+			}
+			if tt.name == "handler error" && !errors.Is(returned, notFound) {
+				t.Errorf("returned error = %v, want the handler error", returned)
+			}
+			// The error response is written once, by the first ErrorHandler call
+			if (tt.wantErr && !json.Valid(rec.Body.Bytes())) || strings.Contains(logs.String(), "after response was written") {
+				t.Errorf("error handled twice: body %s, logs %s", rec.Body, logs.String())
+			}
+
+			labels := map[string]string{"method": http.MethodGet, "route": tt.route, "status": strconv.Itoa(tt.status)}
+			if got := requestCount(t, reg, labels); got != 1 {
+				t.Errorf("requests with %v = %v, want 1", labels, got)
+			}
+		})
+	}
+}
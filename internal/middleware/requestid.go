@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"echo-todo/internal/requestid"
+)
+
+// RequestIDContextKey is the echo.Context key holding the request id
+const RequestIDContextKey = "request_id"
+
+// maxRequestIDLength bounds incoming ids so they are safe to log
+const maxRequestIDLength = 128
+
+// RequestID adds a request ID to each request. An incoming X-Request-ID is
+// kept when it is a short printable string, otherwise a UUID is generated.
+// The id is stored on echo.Context and the request context and echoed in
+// the X-Request-ID response header.
+func RequestID() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			id := c.Request().Header.Get(echo.HeaderXRequestID)
+			if !validRequestID(id) {
+				id = uuid.NewString()
+			}
+
+			// Replace rejected ids so the access log reports the one in use
+			c.Request().Header.Set(echo.HeaderXRequestID, id)
+			c.Set(RequestIDContextKey, id)
+			c.SetRequest(c.Request().WithContext(requestid.WithID(c.Request().Context(), id)))
+			c.Response().Header().Set(echo.HeaderXRequestID, id)
+			return next(c)
+		}
+	}
+}
+
+// validRequestID accepts visible ASCII ids, keeping log lines intact
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"echo-todo/internal/apperror"
+	"echo-todo/internal/requestid"
+	"echo-todo/pkg/utils"
+)
+
+// newRequestIDServer serves GET / behind RequestID, answering with the id
+// of the request context, and GET /fail failing with a not found error
+func newRequestIDServer() *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler(testLogger())
+	e.Use(RequestID())
+	e.GET("/", func(c echo.Context) error {
+		id := requestid.FromContext(c.Request().Context())
+		if c.Get(RequestIDContextKey) != id || c.Request().Header.Get(echo.HeaderXRequestID) != id {
+			return c.String(http.StatusInternalServerError, "request id differs between contexts")
+		}
+		return c.String(http.StatusOK, id)
+	})
+	e.GET("/fail", func(c echo.Context) error {
+		return apperror.NotFound("todo not found")
+	})
+	return e
+}
+
+func TestRequestID(t *testing.T) {
+	e := newRequestIDServer()
+	longest := strings.Repeat("a", maxRequestIDLength)
+
+	tests := []struct {
+		name    string
+		inbound string
+		keep    bool
+	}{
+		{"uuid", "0b7e6a2c-3f0d-4a47-9d8e-2f3c1b5a6d4e", true},
+		{"printable", "trace:abc/123_~!", true},
+		{"longest", longest, true},
+		{"missing", "", false},
+		{"too long", longest + "a", false},
+		{"space", "abc def", false},
+		{"control", "abc\x1bdef", false},
+		{"line break", "abc\ndef", false},
+		{"non ascii", "abcdé", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.inbound != "" {
+				req.Header[echo.HeaderXRequestID] = []string{tt.inbound}
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+
+			id := rec.Header().Get(echo.HeaderXRequestID)
+			if id != rec.Body.String() {
+				t.Errorf("X-Request-ID = %q, request context id = %q", id, rec.Body)
+			}
+			if tt.keep && id != tt.inbound {
+				t.Errorf("X-Request-ID = %q, want the inbound %q", id, tt.inbound)
+			}
+			if !tt.keep {
+				if _, err := uuid.Parse(id); err != nil || id == tt.inbound {
+					t.Errorf("X-Request-ID = %q, want a generated UUID", id)
+				}
+			}
+		})
+	}
+
+	// Every request without an id gets a new one
+	first, second := httptest.NewRecorder(), httptest.NewRecorder()
+	e.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/", nil))
+	e.ServeHTTP(second, httptest.NewRequest(http.MethodGet, "/", nil))
+	if first.Body.String() == second.Body.String() {
+		t.Errorf("two requests got the same id %q", first.Body)
+	}
+}
+
+// TestRequestIDInErrorBody checks error responses name the request id in
+// both error formats
+func TestRequestIDInErrorBody(t *testing.T) {
+	e := newRequestIDServer()
+	const id = "req-123"
+
+	tests := []struct {
+		name   string
+		accept string
+		field  func(body []byte) (string, error)
+	}{
+		{"json", echo.MIMEApplicationJSON, func(body []byte) (string, error) {
+			var res utils.Response
+			err := json.Unmarshal(body, &res)
+			return res.RequestID, err
+		}},
+		{"problem json", utils.MIMEApplicationProblemJSON, func(body []byte) (string, error) {
+			var problem utils.Problem
+			err := json.Unmarshal(body, &problem)
+			return problem.Instance, err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/fail", nil)
+			req.Header.Set(echo.HeaderXRequestID, id)
+			req.Header.Set(echo.HeaderAccept, tt.accept)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want 404", rec.Code)
+			}
+			got, err := tt.field(rec.Body.Bytes())
+			if err != nil {
+				t.Fatalf("decoding %s: %v", rec.Body, err)
+			}
+			if got != id {
+				t.Errorf("request id in body = %q, want %q", got, id)
+			}
+		})
+	}
+}
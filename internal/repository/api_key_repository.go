@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+
+	"echo-todo/pkg/models"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	// GetByID and GetByHash return ErrAPIKeyNotFound if there is no such key.
+	// Both return the current state of the key, including a revocation or
+	// rotation that has just been written.
+	GetByID(ctx context.Context, ownerID, id string) (*models.APIKey, error)
+	GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	GetAll(ctx context.Context, ownerID string) ([]models.APIKey, error)
+	// Rotate replaces the secret of a key and Revoke revokes it, writing
+	// only the changed attributes. Both return ErrAPIKeyNotFound if the key
+	// does not exist and ErrAPIKeyRevoked if it is revoked, even if that
+	// happened after the caller read it, so a revoked key is never restored.
+	Rotate(ctx context.Context, ownerID, id, prefix, keyHash string, rotatedAt time.Time) error
+	Revoke(ctx context.Context, ownerID, id string, revokedAt time.Time) error
+	UpdateLastUsed(ctx context.Context, ownerID, id string, usedAt time.Time) error
+}
+
+// sortAPIKeys orders keys oldest first
+func sortAPIKeys(keys []models.APIKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+}
+
+// dynamoKeyHashIndex is the GSI looking up API keys by their secret hash
+const dynamoKeyHashIndex = "key_hash-index"
+
+// DynamoDBAPIKeyRepository stores API keys in their own table keyed by
+// owner_id (partition) and id (sort), with a GSI on key_hash.
+type DynamoDBAPIKeyRepository struct {
+	client    *dynamodb.Client
+	tableName string
+	logger    *slog.Logger
+}
+
+func NewDynamoDBAPIKeyRepository(tableName string, logger *slog.Logger, optFns ...func(*config.LoadOptions) error) (*DynamoDBAPIKeyRepository, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO(), optFns...)
+	if err != nil {
+		logger.Error("unable to load SDK config", "error", err)
+		return nil, err
+	}
+	// Trace every DynamoDB call as a child span of the request
+	otelaws.AppendMiddlewares(&cfg.APIOptions)
+
+	client := dynamodb.NewFromConfig(cfg)
+
+	return &DynamoDBAPIKeyRepository{
+		client:    client,
+		tableName: tableName,
+		logger:    logger,
+	}, nil
+}
+
+// Ping checks the API keys table is available
+func (r *DynamoDBAPIKeyRepository) Ping(ctx context.Context) error {
+	return pingDynamoDBTable(ctx, r.client, r.tableName)
+}
+
+func (r *DynamoDBAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	item, err := attributevalue.MarshalMap(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+	return storageError(err)
+}
+
+func (r *DynamoDBAPIKeyRepository) GetByID(ctx context.Context, ownerID, id string) (*models.APIKey, error) {
+	// API keys share the key schema of todos. Reads are consistent so a
+	// key is never used or changed after it was rotated or revoked.
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            dynamoTodoKey(ownerID, id),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	if result.Item == nil {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	var key models.APIKey
+	err = attributevalue.UnmarshalMap(result.Item, &key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func (r *DynamoDBAPIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	expr, err := expression.NewBuilder().
+		WithKeyCondition(expression.Key("key_hash").Equal(expression.Value(keyHash))).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(r.tableName),
+		IndexName:                 aws.String(dynamoKeyHashIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Limit:                     aws.Int32(1),
+	})
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	if len(result.Items) == 0 {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	var found models.APIKey
+	err = attributevalue.UnmarshalMap(result.Items[0], &found)
+	if err != nil {
+		return nil, err
+	}
+
+	// The GSI is eventually consistent and may still list a key under a
+	// secret it was rotated away from or without its revocation. Read the
+	// current item and check the secret again.
+	key, err := r.GetByID(ctx, found.OwnerID, found.ID)
+	if err != nil {
+		return nil, err
+	}
+	if key.KeyHash != keyHash {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	return key, nil
+}
+
+func (r *DynamoDBAPIKeyRepository) GetAll(ctx context.Context, ownerID string) ([]models.APIKey, error) {
+	expr, err := expression.NewBuilder().
+		WithKeyCondition(expression.Key("owner_id").Equal(expression.Value(ownerID))).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []models.APIKey{}
+	paginator := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:                 aws.String(r.tableName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, storageError(err)
+		}
+
+		var items []models.APIKey
+		err = attributevalue.UnmarshalListOfMaps(result.Items, &items)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, items...)
+	}
+
+	sortAPIKeys(keys)
+	return keys, nil
+}
+
+func (r *DynamoDBAPIKeyRepository) Rotate(ctx context.Context, ownerID, id, prefix, keyHash string, rotatedAt time.Time) error {
+	return r.updateActive(ctx, ownerID, id, expression.
+		Set(expression.Name("prefix"), expression.Value(prefix)).
+		Set(expression.Name("key_hash"), expression.Value(keyHash)).
+		Set(expression.Name("rotated_at"), expression.Value(rotatedAt)))
+}
+
+func (r *DynamoDBAPIKeyRepository) Revoke(ctx context.Context, ownerID, id string, revokedAt time.Time) error {
+	return r.updateActive(ctx, ownerID, id, expression.Set(expression.Name("revoked_at"), expression.Value(revokedAt)))
+}
+
+// updateActive applies update to a key that exists and is not revoked. The
+// old item returned by a failed condition tells the two cases apart.
+func (r *DynamoDBAPIKeyRepository) updateActive(ctx context.Context, ownerID, id string, update expression.UpdateBuilder) error {
+	expr, err := expression.NewBuilder().
+		WithUpdate(update).
+		WithCondition(expression.AttributeExists(expression.Name("id")).
+			And(expression.AttributeNotExists(expression.Name("revoked_at")))).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                           aws.String(r.tableName),
+		Key:                                 dynamoTodoKey(ownerID, id),
+		UpdateExpression:                    expr.Update(),
+		ConditionExpression:                 expr.Condition(),
+		ExpressionAttributeNames:            expr.Names(),
+		ExpressionAttributeValues:           expr.Values(),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		if len(conditionFailed.Item) == 0 {
+			return ErrAPIKeyNotFound
+		}
+		return ErrAPIKeyRevoked
+	}
+	return storageError(err)
+}
+
+func (r *DynamoDBAPIKeyRepository) UpdateLastUsed(ctx context.Context, ownerID, id string, usedAt time.Time) error {
+	expr, err := expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name("last_used_at"), expression.Value(usedAt))).
+		WithCondition(expression.AttributeExists(expression.Name("id"))).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(r.tableName),
+		Key:                       dynamoTodoKey(ownerID, id),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	// The key was deleted in the meantime, nothing to record
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		r.logger.DebugContext(ctx, "api key deleted before its use was recorded", "api_key_id", id)
+		return nil
+	}
+	return storageError(err)
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"echo-todo/pkg/models"
+)
+
+// apiKeyBackend opens an APIKeyRepository of one storage backend for a test
+type apiKeyBackend struct {
+	name string
+	open func(t *testing.T) APIKeyRepository
+}
+
+// apiKeyBackends returns the API key repositories of the todo backends
+func apiKeyBackends() []apiKeyBackend {
+	backends := []apiKeyBackend{
+		{name: "memory", open: func(t *testing.T) APIKeyRepository { return NewMemoryAPIKeyRepository() }},
+	}
+	for _, backend := range todoBackends() {
+		open := backend.open
+		switch backend.name {
+		case "sqlite":
+			backends = append(backends, apiKeyBackend{name: backend.name, open: func(t *testing.T) APIKeyRepository {
+				return open(t).(*SQLiteTodoRepository).APIKeys()
+			}})
+		case "postgres":
+			backends = append(backends, apiKeyBackend{name: backend.name, open: func(t *testing.T) APIKeyRepository {
+				return open(t).(*PostgresTodoRepository).APIKeys()
+			}})
+		}
+	}
+	return backends
+}
+
+// TestAPIKeyGetByHash checks a lookup by secret sees a rotation or
+// revocation at once
+func TestAPIKeyGetByHash(t *testing.T) {
+	for _, backend := range apiKeyBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			repo := backend.open(t)
+			ctx := context.Background()
+			suffix := fmt.Sprint(time.Now().UnixNano())
+			key := models.APIKey{
+				ID: "k" + suffix, OwnerID: "owner" + suffix, Name: "k", Prefix: "etk_a", KeyHash: "hash1" + suffix,
+				Scopes: []string{models.ScopeTodosRead}, CreatedAt: testTime,
+			}
+			if err := repo.Create(ctx, &key); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			got, err := repo.GetByHash(ctx, key.KeyHash)
+			if err != nil {
+				t.Fatalf("GetByHash() error = %v", err)
+			}
+			if got.ID != key.ID || got.OwnerID != key.OwnerID || got.Revoked() {
+				t.Errorf("GetByHash() = %+v", got)
+			}
+
+			if err := repo.Rotate(ctx, key.OwnerID, key.ID, "etk_b", "hash2"+suffix, minutes(1)); err != nil {
+				t.Fatalf("Rotate() error = %v", err)
+			}
+			if _, err := repo.GetByHash(ctx, key.KeyHash); !errors.Is(err, ErrAPIKeyNotFound) {
+				t.Errorf("GetByHash(rotated away) error = %v, want ErrAPIKeyNotFound", err)
+			}
+
+			if err := repo.Revoke(ctx, key.OwnerID, key.ID, minutes(2)); err != nil {
+				t.Fatalf("Revoke() error = %v", err)
+			}
+			got, err = repo.GetByHash(ctx, "hash2"+suffix)
+			if err == nil && !got.Revoked() {
+				t.Errorf("GetByHash(revoked) = %+v, want it revoked", got)
+			}
+			if err != nil && !errors.Is(err, ErrAPIKeyNotFound) {
+				t.Errorf("GetByHash(revoked) error = %v", err)
+			}
+			if err := repo.Rotate(ctx, key.OwnerID, key.ID, "etk_c", "hash3"+suffix, minutes(3)); !errors.Is(err, ErrAPIKeyRevoked) {
+				t.Errorf("Rotate(revoked) error = %v, want ErrAPIKeyRevoked", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"echo-todo/pkg/models"
+)
+
+// keysetCursor marks the last todo of a page ordered by (sort value, id)
+type keysetCursor struct {
+	Sort  string `json:"sort"`
+	Value string `json:"value"`
+	ID    string `json:"id"`
+}
+
+// encodeCursor serializes backend specific paging state into an opaque token
+func encodeCursor(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor parses a token produced by encodeCursor
+func decodeCursor(cursor string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// pageLimit falls back to the default page size when none was requested
+func pageLimit(opts models.TodoListOptions) int {
+	if opts.Limit <= 0 {
+		return models.DefaultTodoListLimit
+	}
+	return opts.Limit
+}
+
+// decodeKeysetCursor returns nil for an empty cursor
+func decodeKeysetCursor(opts models.TodoListOptions) (*keysetCursor, error) {
+	if opts.Cursor == "" {
+		return nil, nil
+	}
+
+	var c keysetCursor
+	if err := decodeCursor(opts.Cursor, &c); err != nil {
+		return nil, err
+	}
+	if c.ID == "" || c.Sort != sortSpec(opts) {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// keysetPage trims todos fetched with limit+1 rows to a page and computes
+// the cursor of the next page
+func keysetPage(todos []models.Todo, opts models.TodoListOptions, limit int) (*models.TodoPage, error) {
+	page := &models.TodoPage{Todos: todos}
+	if len(todos) <= limit {
+		return page, nil
+	}
+
+	page.Todos = todos[:limit]
+	last := page.Todos[limit-1]
+	next, err := encodeCursor(keysetCursor{
+		Sort:  sortSpec(opts),
+		Value: sortValue(last, sortField(opts)),
+		ID:    last.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = next
+
+	return page, nil
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"echo-todo/internal/apperror"
+)
+
+var (
+	// ErrTodoNotFound is returned for a todo that does not exist
+	ErrTodoNotFound = apperror.NotFound("todo not found")
+	// ErrAPIKeyNotFound is returned for an API key that does not exist
+	ErrAPIKeyNotFound = apperror.NotFound("api key not found")
+	// ErrAPIKeyRevoked is returned when changing an API key that is revoked
+	ErrAPIKeyRevoked = apperror.Conflict("api key is revoked")
+	// ErrVersionConflict is returned when a todo changed since the version the
+	// caller based its write on
+	ErrVersionConflict = apperror.Conflict("todo version conflict")
+	// ErrInvalidCursor is returned when a list cursor cannot be decoded or
+	// was issued for a different sort order
+	ErrInvalidCursor = apperror.Validation("invalid cursor")
+)
+
+// storageError marks failures of the backing store that are likely to go
+// away on retry as unavailable and returns other errors unchanged
+func storageError(err error) error {
+	var throughputExceeded *types.ProvisionedThroughputExceededException
+	var requestLimitExceeded *types.RequestLimitExceeded
+	var internalServerError *types.InternalServerError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &throughputExceeded),
+		errors.As(err, &requestLimitExceeded),
+		errors.As(err, &internalServerError),
+		errors.As(err, &netErr),
+		errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, context.DeadlineExceeded):
+		return apperror.Unavailable("storage is temporarily unavailable", err)
+	default:
+		return err
+	}
+}
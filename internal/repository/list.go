@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"echo-todo/pkg/models"
+)
+
+// sortableTimeFormat is fixed width UTC so stored timestamps sort lexically
+const sortableTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+func formatSortableTime(t time.Time) string {
+	return t.UTC().Format(sortableTimeFormat)
+}
+
+// sortField returns the requested sort attribute, created_at by default
+func sortField(opts models.TodoListOptions) models.TodoSortField {
+	if opts.SortBy == "" {
+		return models.TodoSortCreatedAt
+	}
+	return opts.SortBy
+}
+
+// sortSpec identifies the sort order a cursor was issued for
+func sortSpec(opts models.TodoListOptions) string {
+	if opts.SortDesc {
+		return "-" + string(sortField(opts))
+	}
+	return string(sortField(opts))
+}
+
+// sortValue returns the value of field as it is compared for ordering
+func sortValue(todo models.Todo, field models.TodoSortField) string {
+	switch field {
+	case models.TodoSortUpdatedAt:
+		return formatSortableTime(todo.UpdatedAt)
+	case models.TodoSortTitle:
+		return todo.Title
+	default:
+		return formatSortableTime(todo.CreatedAt)
+	}
+}
+
+// matchesFilters reports whether todo passes the filters in opts
+func matchesFilters(todo models.Todo, opts models.TodoListOptions) bool {
+	if opts.Completed != nil && todo.Completed != *opts.Completed {
+		return false
+	}
+	if opts.CreatedAfter != nil && !todo.CreatedAt.After(*opts.CreatedAfter) {
+		return false
+	}
+	if opts.CreatedBefore != nil && !todo.CreatedAt.Before(*opts.CreatedBefore) {
+		return false
+	}
+	if opts.UpdatedSince != nil && todo.UpdatedAt.Before(*opts.UpdatedSince) {
+		return false
+	}
+	return true
+}
+
+// sqlListQuery builds a keyset paginated SELECT of the todos of ownerID for
+// the filters and sort order in opts. placeholder renders the n-th bind
+// parameter and timeArg converts timestamps to the representation stored by
+// the backend.
+func sqlListQuery(ownerID string, opts models.TodoListOptions, after *keysetCursor, limit int,
+	placeholder func(n int) string, timeArg func(t time.Time) interface{}) (string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+	bind := func(v interface{}) string {
+		args = append(args, v)
+		return placeholder(len(args))
+	}
+
+	conditions = append(conditions, "owner_id = "+bind(ownerID))
+	if opts.Completed != nil {
+		conditions = append(conditions, "completed = "+bind(*opts.Completed))
+	}
+	if opts.CreatedAfter != nil {
+		conditions = append(conditions, "created_at > "+bind(timeArg(*opts.CreatedAfter)))
+	}
+	if opts.CreatedBefore != nil {
+		conditions = append(conditions, "created_at < "+bind(timeArg(*opts.CreatedBefore)))
+	}
+	if opts.UpdatedSince != nil {
+		conditions = append(conditions, "updated_at >= "+bind(timeArg(*opts.UpdatedSince)))
+	}
+
+	// Column names come from a fixed set, never from user input
+	field := sortField(opts)
+	column := string(field)
+	direction, comparison := "ASC", ">"
+	if opts.SortDesc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if after != nil {
+		var value interface{} = after.Value
+		if field != models.TodoSortTitle {
+			t, err := time.Parse(sortableTimeFormat, after.Value)
+			if err != nil {
+				return "", nil, ErrInvalidCursor
+			}
+			value = timeArg(t)
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)",
+			column, comparison, bind(value), bind(after.ID)))
+	}
+
+	query := "SELECT id, owner_id, title, description, completed, created_at, updated_at, version FROM todos" +
+		" WHERE " + strings.Join(conditions, " AND ")
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %s", column, direction, direction, bind(limit+1))
+
+	return query, args, nil
+}
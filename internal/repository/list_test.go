@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"echo-todo/internal/apperror"
+	"echo-todo/pkg/models"
+)
+
+// seedListTodos creates todos with ties on every sort field:
+//
+//	id  title   completed  created  updated
+//	t1  banana  false      +0       +50
+//	t2  apple   true       +10      +10
+//	t3  cherry  false      +10      +30
+//	t4  apple   false      +20      +40
+//	t5  date    true       +30      +30
+func seedListTodos(t *testing.T, repo TodoRepository, ownerID string) {
+	t.Helper()
+	for _, todo := range []struct {
+		id               string
+		title            string
+		completed        bool
+		created, updated int
+	}{
+		{"t1", "banana", false, 0, 50},
+		{"t2", "apple", true, 10, 10},
+		{"t3", "cherry", false, 10, 30},
+		{"t4", "apple", false, 20, 40},
+		{"t5", "date", true, 30, 30},
+	} {
+		createTestTodo(t, repo, models.Todo{
+			ID:        todo.id,
+			OwnerID:   ownerID,
+			Title:     todo.title,
+			Completed: todo.completed,
+			CreatedAt: minutes(todo.created),
+			UpdatedAt: minutes(todo.updated),
+		})
+	}
+	// Todos of other owners are never listed, even with the same id
+	createTestTodo(t, repo, models.Todo{
+		ID: "t1", OwnerID: ownerID + "other", Title: "apple", CreatedAt: minutes(5), UpdatedAt: minutes(5),
+	})
+}
+
+// listAll follows the cursors of the listing from the first page and
+// returns the ids
+func listAll(t *testing.T, repo TodoRepository, ownerID string, opts models.TodoListOptions) []string {
+	t.Helper()
+	ids := []string{}
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatalf("listing %+v does not end", opts)
+		}
+		page, err := repo.GetAll(context.Background(), ownerID, opts)
+		if err != nil {
+			t.Fatalf("GetAll(%+v) error = %v", opts, err)
+		}
+		if len(page.Todos) > opts.Limit {
+			t.Fatalf("GetAll(%+v) returned %d todos", opts, len(page.Todos))
+		}
+		for _, todo := range page.Todos {
+			if todo.OwnerID != ownerID {
+				t.Fatalf("GetAll(%+v) returned todo %s of %s", opts, todo.ID, todo.OwnerID)
+			}
+			ids = append(ids, todo.ID)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+func TestGetAllContract(t *testing.T) {
+	yes, no := true, false
+	at := func(n int) *time.Time {
+		t := minutes(n)
+		return &t
+	}
+	tests := []struct {
+		name string
+		opts models.TodoListOptions
+		want []string
+	}{
+		{"created_at by default", models.TodoListOptions{}, []string{"t1", "t2", "t3", "t4", "t5"}},
+		{"-created_at breaks ties by id descending", models.TodoListOptions{SortBy: models.TodoSortCreatedAt, SortDesc: true},
+			[]string{"t5", "t4", "t3", "t2", "t1"}},
+		{"title breaks ties by id", models.TodoListOptions{SortBy: models.TodoSortTitle},
+			[]string{"t2", "t4", "t1", "t3", "t5"}},
+		{"-title", models.TodoListOptions{SortBy: models.TodoSortTitle, SortDesc: true},
+			[]string{"t5", "t3", "t1", "t4", "t2"}},
+		{"updated_at", models.TodoListOptions{SortBy: models.TodoSortUpdatedAt},
+			[]string{"t2", "t3", "t5", "t4", "t1"}},
+		{"-updated_at", models.TodoListOptions{SortBy: models.TodoSortUpdatedAt, SortDesc: true},
+			[]string{"t1", "t4", "t5", "t3", "t2"}},
+		{"completed", models.TodoListOptions{Completed: &yes}, []string{"t2", "t5"}},
+		{"open newest first", models.TodoListOptions{Completed: &no, SortDesc: true}, []string{"t4", "t3", "t1"}},
+		{"open by title", models.TodoListOptions{Completed: &no, SortBy: models.TodoSortTitle}, []string{"t4", "t1", "t3"}},
+		{"created_after is exclusive", models.TodoListOptions{CreatedAfter: at(10)}, []string{"t4", "t5"}},
+		{"created_after and created_before", models.TodoListOptions{CreatedAfter: at(0), CreatedBefore: at(30)},
+			[]string{"t2", "t3", "t4"}},
+		{"created_after of open todos", models.TodoListOptions{Completed: &no, CreatedAfter: at(0)}, []string{"t3", "t4"}},
+		{"created_after by -updated_at", models.TodoListOptions{CreatedAfter: at(0), SortBy: models.TodoSortUpdatedAt, SortDesc: true},
+			[]string{"t4", "t5", "t3", "t2"}},
+		{"updated_since is inclusive", models.TodoListOptions{UpdatedSince: at(30)}, []string{"t1", "t3", "t4", "t5"}},
+		{"no match", models.TodoListOptions{Completed: &yes, CreatedAfter: at(30)}, []string{}},
+	}
+
+	forEachBackend(t, func(t *testing.T, repo TodoRepository, ownerID string) {
+		seedListTodos(t, repo, ownerID)
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				// Every page size yields the same listing
+				for _, limit := range []int{1, 2, len(tt.want) + 1} {
+					opts := tt.opts
+					opts.Limit = limit
+					if got := listAll(t, repo, ownerID, opts); !slices.Equal(got, tt.want) {
+						t.Errorf("limit %d: got %v, want %v", limit, got, tt.want)
+					}
+				}
+			})
+		}
+	})
+}
+
+func TestGetAllInvalidCursor(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo TodoRepository, ownerID string) {
+		seedListTodos(t, repo, ownerID)
+		ctx := context.Background()
+
+		first, err := repo.GetAll(ctx, ownerID, models.TodoListOptions{Limit: 2})
+		if err != nil {
+			t.Fatalf("GetAll() error = %v", err)
+		}
+		if first.NextCursor == "" {
+			t.Fatal("first page has no cursor")
+		}
+
+		tests := []struct {
+			name string
+			opts models.TodoListOptions
+		}{
+			{"reversed sort", models.TodoListOptions{SortDesc: true}},
+			{"other sort field", models.TodoListOptions{SortBy: models.TodoSortTitle}},
+			{"other sort field reversed", models.TodoListOptions{SortBy: models.TodoSortUpdatedAt, SortDesc: true}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				tt.opts.Limit = 2
+				tt.opts.Cursor = first.NextCursor
+				_, err := repo.GetAll(ctx, ownerID, tt.opts)
+				if !errors.Is(err, ErrInvalidCursor) {
+					t.Fatalf("GetAll() error = %v, want ErrInvalidCursor", err)
+				}
+				if status := apperror.KindOf(err).Status(); status != http.StatusBadRequest {
+					t.Errorf("status of %v = %d, want 400", err, status)
+				}
+			})
+		}
+
+		for _, cursor := range []string{"garbage", "e30", "!"} {
+			_, err := repo.GetAll(ctx, ownerID, models.TodoListOptions{Limit: 2, Cursor: cursor})
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("GetAll(cursor %q) error = %v, want ErrInvalidCursor", cursor, err)
+			}
+		}
+	})
+}
+
+// TestDecodeDynamoDBCursor checks only keys of the queried index and owner
+// are accepted as ExclusiveStartKey
+func TestDecodeDynamoDBCursor(t *testing.T) {
+	open := false
+	opts := models.TodoListOptions{Completed: &open, SortBy: models.TodoSortUpdatedAt}
+	index, _, _ := dynamoListQuery("alice", opts)
+	validKey := func() map[string]interface{} {
+		return map[string]interface{}{
+			"status_pk":  "alice#open",
+			"updated_at": "2026-01-01T00:00:00.000000000Z",
+			"owner_id":   "alice",
+			"id":         "t1",
+		}
+	}
+	cursor := func(index string, change func(key map[string]interface{})) string {
+		key := validKey()
+		change(key)
+		c, err := encodeCursor(dynamoDBCursor{Index: index, Sort: sortSpec(opts), Key: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	keep := func(map[string]interface{}) {}
+
+	opts.Cursor = cursor(index.name, keep)
+	key, err := decodeDynamoDBCursor(opts, "alice", index)
+	if err != nil {
+		t.Fatalf("decodeDynamoDBCursor(valid) error = %v", err)
+	}
+	if len(key) != 4 {
+		t.Errorf("decodeDynamoDBCursor(valid) = %v", key)
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"other index", cursor("list_pk-updated_at-index", keep)},
+		{"other owner", cursor(index.name, func(key map[string]interface{}) {
+			key["status_pk"], key["owner_id"] = "bob#open", "bob"
+		})},
+		{"other owner partition", cursor(index.name, func(key map[string]interface{}) { key["status_pk"] = "bob#open" })},
+		{"other status", cursor(index.name, func(key map[string]interface{}) { key["status_pk"] = "alice#completed" })},
+		{"other table owner", cursor(index.name, func(key map[string]interface{}) { key["owner_id"] = "bob" })},
+		{"extra attribute", cursor(index.name, func(key map[string]interface{}) { key["title"] = "a" })},
+		{"missing sort key", cursor(index.name, func(key map[string]interface{}) { delete(key, "updated_at") })},
+		{"sort key of another index", cursor(index.name, func(key map[string]interface{}) {
+			delete(key, "updated_at")
+			key["created_at"] = "2026-01-01T00:00:00.000000000Z"
+		})},
+		{"empty id", cursor(index.name, func(key map[string]interface{}) { key["id"] = "" })},
+		{"number", cursor(index.name, func(key map[string]interface{}) { key["id"] = 1 })},
+		{"map", cursor(index.name, func(key map[string]interface{}) { key["id"] = map[string]interface{}{"S": "t1"} })},
+		{"no key", cursor(index.name, func(key map[string]interface{}) { clear(key) })},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts.Cursor = tt.cursor
+			_, err := decodeDynamoDBCursor(opts, "alice", index)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeDynamoDBCursor() error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"echo-todo/pkg/models"
+)
+
+// MemoryAPIKeyRepository is a concurrency-safe in-memory APIKeyRepository
+// for local development and tests. Data is lost when the process exits.
+type MemoryAPIKeyRepository struct {
+	mu   sync.RWMutex
+	keys map[memoryTodoKey]models.APIKey
+}
+
+func NewMemoryAPIKeyRepository() *MemoryAPIKeyRepository {
+	return &MemoryAPIKeyRepository{
+		keys: make(map[memoryTodoKey]models.APIKey),
+	}
+}
+
+func (r *MemoryAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys[memoryTodoKey{ownerID: key.OwnerID, id: key.ID}] = cloneAPIKey(*key)
+	return nil
+}
+
+func (r *MemoryAPIKeyRepository) GetByID(ctx context.Context, ownerID, id string) (*models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[memoryTodoKey{ownerID: ownerID, id: id}]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	key = cloneAPIKey(key)
+	return &key, nil
+}
+
+func (r *MemoryAPIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.KeyHash == keyHash {
+			key = cloneAPIKey(key)
+			return &key, nil
+		}
+	}
+
+	return nil, ErrAPIKeyNotFound
+}
+
+func (r *MemoryAPIKeyRepository) GetAll(ctx context.Context, ownerID string) ([]models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := []models.APIKey{}
+	for k, key := range r.keys {
+		if k.ownerID == ownerID {
+			keys = append(keys, cloneAPIKey(key))
+		}
+	}
+
+	sortAPIKeys(keys)
+	return keys, nil
+}
+
+func (r *MemoryAPIKeyRepository) Rotate(ctx context.Context, ownerID, id, prefix, keyHash string, rotatedAt time.Time) error {
+	return r.updateActive(ctx, ownerID, id, func(key *models.APIKey) {
+		key.Prefix = prefix
+		key.KeyHash = keyHash
+		key.RotatedAt = &rotatedAt
+	})
+}
+
+func (r *MemoryAPIKeyRepository) Revoke(ctx context.Context, ownerID, id string, revokedAt time.Time) error {
+	return r.updateActive(ctx, ownerID, id, func(key *models.APIKey) {
+		key.RevokedAt = &revokedAt
+	})
+}
+
+// updateActive applies update to a key that exists and is not revoked
+func (r *MemoryAPIKeyRepository) updateActive(ctx context.Context, ownerID, id string, update func(key *models.APIKey)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := memoryTodoKey{ownerID: ownerID, id: id}
+	key, ok := r.keys[k]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	if key.Revoked() {
+		return ErrAPIKeyRevoked
+	}
+	update(&key)
+	r.keys[k] = key
+	return nil
+}
+
+func (r *MemoryAPIKeyRepository) UpdateLastUsed(ctx context.Context, ownerID, id string, usedAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := memoryTodoKey{ownerID: ownerID, id: id}
+	key, ok := r.keys[k]
+	if !ok {
+		return nil
+	}
+	key.LastUsedAt = &usedAt
+	r.keys[k] = key
+	return nil
+}
+
+// cloneAPIKey copies the scopes so callers cannot modify stored keys
+func cloneAPIKey(key models.APIKey) models.APIKey {
+	key.Scopes = append([]string(nil), key.Scopes...)
+	return key
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"echo-todo/pkg/models"
+)
+
+// MemoryTodoRepository is a concurrency-safe in-memory TodoRepository
+// for local development and tests. Data is lost when the process exits.
+type MemoryTodoRepository struct {
+	mu    sync.RWMutex
+	todos map[memoryTodoKey]models.Todo
+}
+
+// memoryTodoKey scopes todo ids to their owner
+type memoryTodoKey struct {
+	ownerID string
+	id      string
+}
+
+func NewMemoryTodoRepository() *MemoryTodoRepository {
+	return &MemoryTodoRepository{
+		todos: make(map[memoryTodoKey]models.Todo),
+	}
+}
+
+func (r *MemoryTodoRepository) Create(ctx context.Context, todo *models.Todo) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.todos[memoryTodoKey{ownerID: todo.OwnerID, id: todo.ID}] = *todo
+	return nil
+}
+
+func (r *MemoryTodoRepository) GetByID(ctx context.Context, ownerID, id string) (*models.Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todo, ok := r.todos[memoryTodoKey{ownerID: ownerID, id: id}]
+	if !ok {
+		return nil, ErrTodoNotFound
+	}
+
+	return &todo, nil
+}
+
+func (r *MemoryTodoRepository) GetAll(ctx context.Context, ownerID string, opts models.TodoListOptions) (*models.TodoPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	after, err := decodeKeysetCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	field := sortField(opts)
+	r.mu.RLock()
+	todos := make([]models.Todo, 0, len(r.todos))
+	for key, todo := range r.todos {
+		if key.ownerID != ownerID || !matchesFilters(todo, opts) {
+			continue
+		}
+		if after != nil && !keysetAfter(todo, field, opts.SortDesc, after) {
+			continue
+		}
+		todos = append(todos, todo)
+	}
+	r.mu.RUnlock()
+
+	// Map iteration order is random, so sort by (sort value, id)
+	sort.Slice(todos, func(i, j int) bool {
+		vi, vj := sortValue(todos[i], field), sortValue(todos[j], field)
+		if vi == vj {
+			vi, vj = todos[i].ID, todos[j].ID
+		}
+		if opts.SortDesc {
+			return vi > vj
+		}
+		return vi < vj
+	})
+
+	limit := pageLimit(opts)
+	if len(todos) > limit+1 {
+		todos = todos[:limit+1]
+	}
+
+	return keysetPage(todos, opts, limit)
+}
+
+// keysetAfter reports whether todo comes after the cursor position
+func keysetAfter(todo models.Todo, field models.TodoSortField, desc bool, c *keysetCursor) bool {
+	value, cursorValue := sortValue(todo, field), c.Value
+	if value == cursorValue {
+		value, cursorValue = todo.ID, c.ID
+	}
+	if desc {
+		return value < cursorValue
+	}
+	return value > cursorValue
+}
+
+func (r *MemoryTodoRepository) Update(ctx context.Context, todo *models.Todo, fields []TodoField) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := memoryTodoKey{ownerID: todo.OwnerID, id: todo.ID}
+	stored, ok := r.todos[key]
+	if !ok {
+		return ErrTodoNotFound
+	}
+	if stored.Version != todo.Version {
+		return ErrVersionConflict
+	}
+
+	for _, field := range fields {
+		switch field {
+		case TodoFieldTitle:
+			stored.Title = todo.Title
+		case TodoFieldDescription:
+			stored.Description = todo.Description
+		case TodoFieldCompleted:
+			stored.Completed = todo.Completed
+		default:
+			return fmt.Errorf("cannot update todo field %q", field)
+		}
+	}
+	stored.UpdatedAt = todo.UpdatedAt
+	stored.Version++
+	r.todos[key] = stored
+	*todo = stored
+	return nil
+}
+
+func (r *MemoryTodoRepository) Delete(ctx context.Context, ownerID, id string, version int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := memoryTodoKey{ownerID: ownerID, id: id}
+	stored, ok := r.todos[key]
+	if !ok {
+		return ErrTodoNotFound
+	}
+	if version != 0 && stored.Version != version {
+		return ErrVersionConflict
+	}
+
+	delete(r.todos, key)
+	return nil
+}
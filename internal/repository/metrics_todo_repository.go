@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"echo-todo/internal/apperror"
+	"echo-todo/internal/metrics"
+	"echo-todo/pkg/models"
+)
+
+// MetricsTodoRepository decorates a TodoRepository with Prometheus metrics
+// recording the latency and errors of every operation
+type MetricsTodoRepository struct {
+	next     TodoRepository
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+// NewMetricsTodoRepository wraps next and registers its metrics with reg.
+// backend labels the metrics with the storage backend of next.
+func NewMetricsTodoRepository(next TodoRepository, backend string, reg prometheus.Registerer) *MetricsTodoRepository {
+	r := &MetricsTodoRepository{
+		next: next,
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   metrics.Namespace,
+			Name:        "todo_repository_operation_duration_seconds",
+			Help:        "Todo repository operation latency by operation.",
+			Buckets:     prometheus.DefBuckets,
+			ConstLabels: prometheus.Labels{"backend": backend},
+		}, []string{"operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   metrics.Namespace,
+			Name:        "todo_repository_operation_errors_total",
+			Help:        "Failed todo repository operations by operation.",
+			ConstLabels: prometheus.Labels{"backend": backend},
+		}, []string{"operation"}),
+	}
+	reg.MustRegister(r.duration, r.errors)
+	return r
+}
+
+// observe records an operation started at start that returned err. Expected
+// outcomes such as a missing todo or a version conflict are not counted as
+// errors.
+func (r *MetricsTodoRepository) observe(operation string, start time.Time, err error) {
+	r.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err == nil {
+		return
+	}
+	switch apperror.KindOf(err) {
+	case apperror.KindInternal, apperror.KindUnavailable:
+		r.errors.WithLabelValues(operation).Inc()
+	}
+}
+
+func (r *MetricsTodoRepository) Create(ctx context.Context, todo *models.Todo) error {
+	start := time.Now()
+	err := r.next.Create(ctx, todo)
+	r.observe("create", start, err)
+	return err
+}
+
+func (r *MetricsTodoRepository) GetByID(ctx context.Context, ownerID, id string) (*models.Todo, error) {
+	start := time.Now()
+	todo, err := r.next.GetByID(ctx, ownerID, id)
+	r.observe("get_by_id", start, err)
+	return todo, err
+}
+
+func (r *MetricsTodoRepository) GetAll(ctx context.Context, ownerID string, opts models.TodoListOptions) (*models.TodoPage, error) {
+	start := time.Now()
+	page, err := r.next.GetAll(ctx, ownerID, opts)
+	r.observe("get_all", start, err)
+	return page, err
+}
+
+func (r *MetricsTodoRepository) Update(ctx context.Context, todo *models.Todo, fields []TodoField) error {
+	start := time.Now()
+	err := r.next.Update(ctx, todo, fields)
+	r.observe("update", start, err)
+	return err
+}
+
+func (r *MetricsTodoRepository) Delete(ctx context.Context, ownerID, id string, version int) error {
+	start := time.Now()
+	err := r.next.Delete(ctx, ownerID, id, version)
+	r.observe("delete", start, err)
+	return err
+}
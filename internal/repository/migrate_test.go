@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"echo-todo/pkg/models"
+)
+
+// sqlTodoRepository is implemented by the repositories of the SQL backends
+type sqlTodoRepository interface {
+	TodoRepository
+	MigrateDown(ctx context.Context, steps int) error
+	Close() error
+}
+
+// testMigrations checks open applies every migration, MigrateDown reverts
+// them one by one and reopening migrates the schema up again
+func testMigrations(t *testing.T, open func(t *testing.T) (sqlTodoRepository, *sql.DB), migrations []migration) {
+	ctx := context.Background()
+	latest := migrations[len(migrations)-1].version
+	assertVersion := func(db *sql.DB, want int) {
+		t.Helper()
+		version, err := schemaVersion(ctx, db)
+		if err != nil {
+			t.Fatalf("schemaVersion() error = %v", err)
+		}
+		if version != want {
+			t.Fatalf("schema version = %d, want %d", version, want)
+		}
+	}
+	assertUsable := func(repo TodoRepository, id string) {
+		t.Helper()
+		todo := createTestTodo(t, repo, models.Todo{ID: id, OwnerID: "migrations", Title: "a", CreatedAt: minutes(0), UpdatedAt: minutes(0)})
+		if err := repo.Update(ctx, &todo, []TodoField{TodoFieldCompleted}); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		if err := repo.Delete(ctx, "migrations", id, todo.Version); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+	}
+
+	repo, db := open(t)
+	assertVersion(db, latest)
+	assertUsable(repo, "migrations1")
+
+	if err := repo.MigrateDown(ctx, 1); err != nil {
+		t.Fatalf("MigrateDown(1) error = %v", err)
+	}
+	assertVersion(db, migrations[len(migrations)-2].version)
+	if err := repo.MigrateDown(ctx, len(migrations)); err != nil {
+		t.Fatalf("MigrateDown(all) error = %v", err)
+	}
+	assertVersion(db, 0)
+	if _, err := db.ExecContext(ctx, "SELECT 1 FROM todos"); err == nil {
+		t.Error("todos table exists after reverting every migration")
+	}
+	if err := repo.MigrateDown(ctx, 1); err != nil {
+		t.Errorf("MigrateDown() of an empty schema error = %v", err)
+	}
+	repo.Close()
+
+	// Opening migrates up again, opening once more has nothing to apply
+	for i := 0; i < 2; i++ {
+		repo, db := open(t)
+		assertVersion(db, latest)
+		assertUsable(repo, "migrations2")
+		repo.Close()
+	}
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	migrations, err := loadMigrations(sqliteMigrations, "migrations/sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "todos.db")
+	testMigrations(t, func(t *testing.T) (sqlTodoRepository, *sql.DB) {
+		repo, err := NewSQLiteTodoRepository(context.Background(), path, testLogger())
+		if err != nil {
+			t.Fatalf("NewSQLiteTodoRepository() error = %v", err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return repo, repo.db
+	}, migrations)
+}
+
+// TestPostgresMigrations drops every table, POSTGRES_TEST_DSN must name a
+// database used only for tests
+func TestPostgresMigrations(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+	migrations, err := loadMigrations(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMigrations(t, func(t *testing.T) (sqlTodoRepository, *sql.DB) {
+		repo := openPostgres(t, dsn)
+		return repo, repo.db
+	}, migrations)
+}
+
+// TestLoadMigrations checks the SQL backends have the same migrations,
+// numbered without gaps
+func TestLoadMigrations(t *testing.T) {
+	sqlite, err := loadMigrations(sqliteMigrations, "migrations/sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	postgres, err := loadMigrations(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sqlite) != len(postgres) {
+		t.Fatalf("%d SQLite migrations, %d PostgreSQL migrations", len(sqlite), len(postgres))
+	}
+	for i := range sqlite {
+		if sqlite[i].version != i+1 || sqlite[i].name != postgres[i].name || postgres[i].version != i+1 {
+			t.Errorf("migration %d is %d_%s in SQLite and %d_%s in PostgreSQL",
+				i+1, sqlite[i].version, sqlite[i].name, postgres[i].version, postgres[i].name)
+		}
+	}
+}
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_todos_updated_at;
+DROP INDEX IF EXISTS idx_todos_completed_created_at;
+DROP INDEX IF EXISTS idx_todos_created_at;
+DROP TABLE IF EXISTS todos;
@@ -0,0 +1,17 @@
+CREATE TABLE todos (
+    id          TEXT PRIMARY KEY,
+    title       TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    completed   BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at  TIMESTAMPTZ NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL
+);
+
+-- Listing in creation order with id as a tie breaker
+CREATE INDEX idx_todos_created_at ON todos (created_at, id);
+
+-- Filtering by completion state, newest first
+CREATE INDEX idx_todos_completed_created_at ON todos (completed, created_at DESC, id);
+
+-- Filtering by last modification
+CREATE INDEX idx_todos_updated_at ON todos (updated_at, id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_todos_completed_updated_at;
+DROP INDEX IF EXISTS idx_todos_title;
@@ -0,0 +1,5 @@
+-- Sorting by title
+CREATE INDEX idx_todos_title ON todos (title, id);
+
+-- Open or completed todos by last modification
+CREATE INDEX idx_todos_completed_updated_at ON todos (completed, updated_at DESC, id);
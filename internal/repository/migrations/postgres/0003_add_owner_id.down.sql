@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_todos_owner_completed_updated_at;
+DROP INDEX IF EXISTS idx_todos_owner_completed_created_at;
+DROP INDEX IF EXISTS idx_todos_owner_title;
+DROP INDEX IF EXISTS idx_todos_owner_updated_at;
+DROP INDEX IF EXISTS idx_todos_owner_created_at;
+
+ALTER TABLE todos DROP COLUMN owner_id;
+
+CREATE INDEX idx_todos_created_at ON todos (created_at, id);
+CREATE INDEX idx_todos_completed_created_at ON todos (completed, created_at DESC, id);
+CREATE INDEX idx_todos_updated_at ON todos (updated_at, id);
+CREATE INDEX idx_todos_title ON todos (title, id);
+CREATE INDEX idx_todos_completed_updated_at ON todos (completed, updated_at DESC, id);
@@ -0,0 +1,16 @@
+-- API keys are looked up by the SHA-256 hash of the secret key, the key
+-- itself is never stored
+CREATE TABLE api_keys (
+    id           TEXT PRIMARY KEY,
+    owner_id     TEXT NOT NULL,
+    name         TEXT NOT NULL,
+    prefix       TEXT NOT NULL,
+    key_hash     TEXT NOT NULL UNIQUE,
+    scopes       TEXT NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL,
+    rotated_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ
+);
+
+CREATE INDEX idx_api_keys_owner_created_at ON api_keys (owner_id, created_at, id);
@@ -0,0 +1,2 @@
+-- Fails if owners share a todo id
+ALTER TABLE todos DROP CONSTRAINT todos_pkey, ADD PRIMARY KEY (id);
@@ -0,0 +1,2 @@
+-- Todo ids are unique per owner, so the same id may exist for several owners
+ALTER TABLE todos DROP CONSTRAINT todos_pkey, ADD PRIMARY KEY (owner_id, id);
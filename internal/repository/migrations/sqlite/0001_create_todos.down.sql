@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_todos_created_at;
+DROP TABLE IF EXISTS todos;
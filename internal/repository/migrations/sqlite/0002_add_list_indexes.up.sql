@@ -0,0 +1,4 @@
+CREATE INDEX idx_todos_updated_at ON todos (updated_at, id);
+CREATE INDEX idx_todos_title ON todos (title, id);
+CREATE INDEX idx_todos_completed_created_at ON todos (completed, created_at, id);
+CREATE INDEX idx_todos_completed_updated_at ON todos (completed, updated_at, id);
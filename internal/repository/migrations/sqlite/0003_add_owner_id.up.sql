@@ -0,0 +1,15 @@
+-- Todos created before ownership existed keep an empty owner and are not
+-- visible to any user until assigned
+ALTER TABLE todos ADD COLUMN owner_id TEXT NOT NULL DEFAULT '';
+
+DROP INDEX IF EXISTS idx_todos_created_at;
+DROP INDEX IF EXISTS idx_todos_updated_at;
+DROP INDEX IF EXISTS idx_todos_title;
+DROP INDEX IF EXISTS idx_todos_completed_created_at;
+DROP INDEX IF EXISTS idx_todos_completed_updated_at;
+
+CREATE INDEX idx_todos_owner_created_at ON todos (owner_id, created_at, id);
+CREATE INDEX idx_todos_owner_updated_at ON todos (owner_id, updated_at, id);
+CREATE INDEX idx_todos_owner_title ON todos (owner_id, title, id);
+CREATE INDEX idx_todos_owner_completed_created_at ON todos (owner_id, completed, created_at, id);
+CREATE INDEX idx_todos_owner_completed_updated_at ON todos (owner_id, completed, updated_at, id);
@@ -0,0 +1,2 @@
+-- Todos written before versioning start at version 1
+ALTER TABLE todos ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
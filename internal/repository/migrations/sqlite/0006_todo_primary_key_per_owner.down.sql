@@ -0,0 +1,23 @@
+-- Fails if owners share a todo id
+CREATE TABLE todos_old (
+    id          TEXT PRIMARY KEY,
+    title       TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    completed   INTEGER NOT NULL DEFAULT 0,
+    created_at  TEXT NOT NULL,
+    updated_at  TEXT NOT NULL,
+    owner_id    TEXT NOT NULL DEFAULT '',
+    version     INTEGER NOT NULL DEFAULT 1
+);
+
+INSERT INTO todos_old (id, title, description, completed, created_at, updated_at, owner_id, version)
+    SELECT id, title, description, completed, created_at, updated_at, owner_id, version FROM todos;
+
+DROP TABLE todos;
+ALTER TABLE todos_old RENAME TO todos;
+
+CREATE INDEX idx_todos_owner_created_at ON todos (owner_id, created_at, id);
+CREATE INDEX idx_todos_owner_updated_at ON todos (owner_id, updated_at, id);
+CREATE INDEX idx_todos_owner_title ON todos (owner_id, title, id);
+CREATE INDEX idx_todos_owner_completed_created_at ON todos (owner_id, completed, created_at, id);
+CREATE INDEX idx_todos_owner_completed_updated_at ON todos (owner_id, completed, updated_at, id);
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"echo-todo/pkg/models"
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// PostgresPoolOptions configures the connection pool
+type PostgresPoolOptions struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+type PostgresTodoRepository struct {
+	db         *sql.DB
+	migrations []migration
+	logger     *slog.Logger
+}
+
+// NewPostgresTodoRepository connects to the database described by dsn,
+// verifies the connection and applies any pending schema migrations.
+func NewPostgresTodoRepository(ctx context.Context, dsn string, pool PostgresPoolOptions, logger *slog.Logger) (*PostgresTodoRepository, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	migrations, err := loadMigrations(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := migrateUp(ctx, db, migrations, logger); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &PostgresTodoRepository{
+		db:         db,
+		migrations: migrations,
+		logger:     logger,
+	}, nil
+}
+
+// MigrateDown reverts the given number of most recently applied migrations
+func (r *PostgresTodoRepository) MigrateDown(ctx context.Context, steps int) error {
+	return migrateDown(ctx, r.db, r.migrations, steps, r.logger)
+}
+
+// Ping checks a connection to the server can be established
+func (r *PostgresTodoRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// Close closes all pooled connections
+func (r *PostgresTodoRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *PostgresTodoRepository) Create(ctx context.Context, todo *models.Todo) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO todos (id, owner_id, title, description, completed, created_at, updated_at, version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		todo.ID, todo.OwnerID, todo.Title, todo.Description, todo.Completed, todo.CreatedAt, todo.UpdatedAt, todo.Version,
+	)
+	return storageError(err)
+}
+
+func (r *PostgresTodoRepository) GetByID(ctx context.Context, ownerID, id string) (*models.Todo, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT id, owner_id, title, description, completed, created_at, updated_at, version
+		FROM todos WHERE owner_id = $1 AND id = $2`, ownerID, id)
+
+	todo, err := scanPostgresTodo(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTodoNotFound
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return todo, nil
+}
+
+func (r *PostgresTodoRepository) GetAll(ctx context.Context, ownerID string, opts models.TodoListOptions) (*models.TodoPage, error) {
+	after, err := decodeKeysetCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := pageLimit(opts)
+	query, args, err := sqlListQuery(ownerID, opts, after, limit,
+		func(n int) string { return "$" + strconv.Itoa(n) },
+		func(t time.Time) interface{} { return t },
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer rows.Close()
+
+	todos := []models.Todo{}
+	for rows.Next() {
+		todo, err := scanPostgresTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, *todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storageError(err)
+	}
+
+	return keysetPage(todos, opts, limit)
+}
+
+func (r *PostgresTodoRepository) Update(ctx context.Context, todo *models.Todo, fields []TodoField) error {
+	return sqlUpdateTodo(ctx, r.db, todo, fields,
+		func(n int) string { return "$" + strconv.Itoa(n) },
+		func(t time.Time) interface{} { return t },
+	)
+}
+
+func (r *PostgresTodoRepository) Delete(ctx context.Context, ownerID, id string, version int) error {
+	return sqlDeleteTodo(ctx, r.db, ownerID, id, version, func(n int) string { return "$" + strconv.Itoa(n) })
+}
+
+func scanPostgresTodo(row rowScanner) (*models.Todo, error) {
+	var todo models.Todo
+	err := row.Scan(&todo.ID, &todo.OwnerID, &todo.Title, &todo.Description, &todo.Completed, &todo.CreatedAt, &todo.UpdatedAt, &todo.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	todo.CreatedAt = todo.CreatedAt.UTC()
+	todo.UpdatedAt = todo.UpdatedAt.UTC()
+
+	return &todo, nil
+}
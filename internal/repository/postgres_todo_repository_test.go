@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"echo-todo/pkg/models"
+)
+
+// The contract tests in todo_repository_test.go and list_test.go cover
+// keyset paging and versioned writes on PostgreSQL when POSTGRES_TEST_DSN
+// is set, e.g. postgres://postgres@localhost:5432/echo_todo_test?sslmode=disable
+
+func TestPostgresPool(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+	repo := openPostgres(t, dsn)
+
+	if got := repo.db.Stats().MaxOpenConnections; got != 4 {
+		t.Errorf("MaxOpenConnections = %d, want 4", got)
+	}
+	if err := repo.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+}
+
+func TestPostgresContextCanceled(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+	repo := openPostgres(t, dsn)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	todo := models.Todo{ID: "canceled", OwnerID: "canceled", Title: "a", CreatedAt: minutes(0), UpdatedAt: minutes(0), Version: 1}
+	for name, call := range map[string]func() error{
+		"Create": func() error { return repo.Create(ctx, &todo) },
+		"GetByID": func() error {
+			_, err := repo.GetByID(ctx, todo.OwnerID, todo.ID)
+			return err
+		},
+		"GetAll": func() error {
+			_, err := repo.GetAll(ctx, todo.OwnerID, models.TodoListOptions{})
+			return err
+		},
+		"Update": func() error { return repo.Update(ctx, &todo, []TodoField{TodoFieldTitle}) },
+		"Delete": func() error { return repo.Delete(ctx, todo.OwnerID, todo.ID, 0) },
+	} {
+		if err := call(); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s() error = %v, want context.Canceled", name, err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"echo-todo/pkg/models"
+)
+
+// SQLAPIKeyRepository stores API keys in the api_keys table of the SQLite
+// or PostgreSQL database holding the todos
+type SQLAPIKeyRepository struct {
+	db *sql.DB
+	// placeholder renders the n-th bind parameter
+	placeholder func(n int) string
+	// timeArg converts timestamps to the representation stored by the backend
+	timeArg func(t time.Time) interface{}
+	// scanTime converts a scanned timestamp column back
+	scanTime func(v interface{}) (time.Time, error)
+}
+
+// APIKeys returns the API key repository sharing the database connection
+func (r *SQLiteTodoRepository) APIKeys() *SQLAPIKeyRepository {
+	return &SQLAPIKeyRepository{
+		db:          r.db,
+		placeholder: func(int) string { return "?" },
+		timeArg:     func(t time.Time) interface{} { return formatSortableTime(t) },
+		scanTime: func(v interface{}) (time.Time, error) {
+			s, ok := v.(string)
+			if !ok {
+				return time.Time{}, errors.New("timestamp column is not text")
+			}
+			return time.Parse(sortableTimeFormat, s)
+		},
+	}
+}
+
+// APIKeys returns the API key repository sharing the connection pool
+func (r *PostgresTodoRepository) APIKeys() *SQLAPIKeyRepository {
+	return &SQLAPIKeyRepository{
+		db:          r.db,
+		placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+		timeArg:     func(t time.Time) interface{} { return t },
+		scanTime: func(v interface{}) (time.Time, error) {
+			t, ok := v.(time.Time)
+			if !ok {
+				return time.Time{}, errors.New("timestamp column is not a time")
+			}
+			return t.UTC(), nil
+		},
+	}
+}
+
+const selectAPIKeys = `SELECT id, owner_id, name, prefix, key_hash, scopes, created_at, rotated_at, last_used_at, revoked_at
+	FROM api_keys`
+
+// rebind replaces the ? bind parameters of query with the backend's placeholders
+func (r *SQLAPIKeyRepository) rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString(r.placeholder(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (r *SQLAPIKeyRepository) optionalTimeArg(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return r.timeArg(*t)
+}
+
+func (r *SQLAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	_, err := r.db.ExecContext(ctx, r.rebind(
+		`INSERT INTO api_keys (id, owner_id, name, prefix, key_hash, scopes, created_at, rotated_at, last_used_at, revoked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		key.ID, key.OwnerID, key.Name, key.Prefix, key.KeyHash, strings.Join(key.Scopes, " "),
+		r.timeArg(key.CreatedAt), r.optionalTimeArg(key.RotatedAt),
+		r.optionalTimeArg(key.LastUsedAt), r.optionalTimeArg(key.RevokedAt),
+	)
+	return storageError(err)
+}
+
+func (r *SQLAPIKeyRepository) GetByID(ctx context.Context, ownerID, id string) (*models.APIKey, error) {
+	row := r.db.QueryRowContext(ctx, r.rebind(selectAPIKeys+" WHERE owner_id = ? AND id = ?"), ownerID, id)
+	return r.scanOne(row)
+}
+
+func (r *SQLAPIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	row := r.db.QueryRowContext(ctx, r.rebind(selectAPIKeys+" WHERE key_hash = ?"), keyHash)
+	return r.scanOne(row)
+}
+
+func (r *SQLAPIKeyRepository) GetAll(ctx context.Context, ownerID string) ([]models.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, r.rebind(selectAPIKeys+" WHERE owner_id = ? ORDER BY created_at, id"), ownerID)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := r.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storageError(err)
+	}
+
+	return keys, nil
+}
+
+func (r *SQLAPIKeyRepository) Rotate(ctx context.Context, ownerID, id, prefix, keyHash string, rotatedAt time.Time) error {
+	return r.updateActive(ctx, ownerID, id, "prefix = ?, key_hash = ?, rotated_at = ?", prefix, keyHash, r.timeArg(rotatedAt))
+}
+
+func (r *SQLAPIKeyRepository) Revoke(ctx context.Context, ownerID, id string, revokedAt time.Time) error {
+	return r.updateActive(ctx, ownerID, id, "revoked_at = ?", r.timeArg(revokedAt))
+}
+
+// updateActive runs the assignments of set, with ? placeholders bound to
+// args, on a key that exists and is not revoked. If no row matched, the key
+// is looked up to tell ErrAPIKeyNotFound from ErrAPIKeyRevoked.
+func (r *SQLAPIKeyRepository) updateActive(ctx context.Context, ownerID, id, set string, args ...interface{}) error {
+	result, err := r.db.ExecContext(ctx,
+		r.rebind("UPDATE api_keys SET "+set+" WHERE owner_id = ? AND id = ? AND revoked_at IS NULL"),
+		append(args, ownerID, id)...)
+	if err != nil {
+		return storageError(err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	if _, err := r.GetByID(ctx, ownerID, id); err != nil {
+		return err
+	}
+	return ErrAPIKeyRevoked
+}
+
+func (r *SQLAPIKeyRepository) UpdateLastUsed(ctx context.Context, ownerID, id string, usedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, r.rebind("UPDATE api_keys SET last_used_at = ? WHERE owner_id = ? AND id = ?"),
+		r.timeArg(usedAt), ownerID, id)
+	return storageError(err)
+}
+
+func (r *SQLAPIKeyRepository) scanOne(row *sql.Row) (*models.APIKey, error) {
+	key, err := r.scan(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return key, nil
+}
+
+func (r *SQLAPIKeyRepository) scan(row rowScanner) (*models.APIKey, error) {
+	var key models.APIKey
+	var scopes string
+	var createdAt, rotatedAt, lastUsedAt, revokedAt interface{}
+	err := row.Scan(&key.ID, &key.OwnerID, &key.Name, &key.Prefix, &key.KeyHash, &scopes,
+		&createdAt, &rotatedAt, &lastUsedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Scopes = strings.Fields(scopes)
+	if key.CreatedAt, err = r.scanTime(createdAt); err != nil {
+		return nil, err
+	}
+	for _, t := range []struct {
+		value interface{}
+		dest  **time.Time
+	}{
+		{rotatedAt, &key.RotatedAt},
+		{lastUsedAt, &key.LastUsedAt},
+		{revokedAt, &key.RevokedAt},
+	} {
+		if t.value == nil {
+			continue
+		}
+		parsed, err := r.scanTime(t.value)
+		if err != nil {
+			return nil, err
+		}
+		*t.dest = &parsed
+	}
+
+	return &key, nil
+}
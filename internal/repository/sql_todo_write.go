@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"echo-todo/pkg/models"
+)
+
+// sqlUpdateTodo writes fields, updated_at and the incremented version of
+// todo with a single UPDATE conditioned on todo.Version, so a todo deleted in
+// the meantime is never recreated. placeholder renders the n-th bind
+// parameter and timeArg converts timestamps to the representation stored by
+// the backend.
+func sqlUpdateTodo(ctx context.Context, db *sql.DB, todo *models.Todo, fields []TodoField,
+	placeholder func(n int) string, timeArg func(t time.Time) interface{}) error {
+	var args []interface{}
+	bind := func(v interface{}) string {
+		args = append(args, v)
+		return placeholder(len(args))
+	}
+
+	// Column names come from a fixed set, never from user input
+	var assignments []string
+	for _, field := range fields {
+		var value interface{}
+		switch field {
+		case TodoFieldTitle:
+			value = todo.Title
+		case TodoFieldDescription:
+			value = todo.Description
+		case TodoFieldCompleted:
+			value = todo.Completed
+		default:
+			return fmt.Errorf("cannot update todo field %q", field)
+		}
+		assignments = append(assignments, string(field)+" = "+bind(value))
+	}
+	assignments = append(assignments,
+		"updated_at = "+bind(timeArg(todo.UpdatedAt)),
+		"version = "+bind(todo.Version+1),
+	)
+
+	query := "UPDATE todos SET " + strings.Join(assignments, ", ") +
+		" WHERE owner_id = " + bind(todo.OwnerID) + " AND id = " + bind(todo.ID) + " AND version = " + bind(todo.Version)
+	result, err := db.ExecContext(ctx, query, args...)
+	if err := sqlVersionedWriteResult(ctx, db, result, err, todo.OwnerID, todo.ID, placeholder); err != nil {
+		return err
+	}
+
+	todo.Version++
+	return nil
+}
+
+// sqlDeleteTodo deletes a todo, only at the given version unless it is zero
+func sqlDeleteTodo(ctx context.Context, db *sql.DB, ownerID, id string, version int, placeholder func(n int) string) error {
+	query := "DELETE FROM todos WHERE owner_id = " + placeholder(1) + " AND id = " + placeholder(2)
+	args := []interface{}{ownerID, id}
+	if version != 0 {
+		query += " AND version = " + placeholder(3)
+		args = append(args, version)
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	return sqlVersionedWriteResult(ctx, db, result, err, ownerID, id, placeholder)
+}
+
+// sqlVersionedWriteResult checks a write conditioned on the version of a
+// todo changed a row. If not, the todo is looked up to tell ErrTodoNotFound
+// from ErrVersionConflict.
+func sqlVersionedWriteResult(ctx context.Context, db *sql.DB, result sql.Result, err error,
+	ownerID, id string, placeholder func(n int) string) error {
+	if err != nil {
+		return storageError(err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	var exists int
+	err = db.QueryRowContext(ctx,
+		"SELECT 1 FROM todos WHERE owner_id = "+placeholder(1)+" AND id = "+placeholder(2), ownerID, id,
+	).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTodoNotFound
+	}
+	if err != nil {
+		return storageError(err)
+	}
+	return ErrVersionConflict
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"log/slog"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"echo-todo/pkg/models"
+)
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+type SQLiteTodoRepository struct {
+	db         *sql.DB
+	migrations []migration
+	logger     *slog.Logger
+}
+
+// NewSQLiteTodoRepository opens the database file at path and applies any
+// pending schema migrations.
+func NewSQLiteTodoRepository(ctx context.Context, path string, logger *slog.Logger) (*SQLiteTodoRepository, error) {
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, so serialize access through one connection
+	db.SetMaxOpenConns(1)
+
+	migrations, err := loadMigrations(sqliteMigrations, "migrations/sqlite")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := migrateUp(ctx, db, migrations, logger); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteTodoRepository{
+		db:         db,
+		migrations: migrations,
+		logger:     logger,
+	}, nil
+}
+
+// MigrateDown reverts the given number of most recently applied migrations
+func (r *SQLiteTodoRepository) MigrateDown(ctx context.Context, steps int) error {
+	return migrateDown(ctx, r.db, r.migrations, steps, r.logger)
+}
+
+// Ping checks the database file can be read
+func (r *SQLiteTodoRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// Close closes the underlying database
+func (r *SQLiteTodoRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *SQLiteTodoRepository) Create(ctx context.Context, todo *models.Todo) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO todos (id, owner_id, title, description, completed, created_at, updated_at, version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		todo.ID, todo.OwnerID, todo.Title, todo.Description, todo.Completed,
+		formatSortableTime(todo.CreatedAt), formatSortableTime(todo.UpdatedAt), todo.Version,
+	)
+	return storageError(err)
+}
+
+func (r *SQLiteTodoRepository) GetByID(ctx context.Context, ownerID, id string) (*models.Todo, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT id, owner_id, title, description, completed, created_at, updated_at, version
+		FROM todos WHERE owner_id = ? AND id = ?`, ownerID, id)
+
+	todo, err := scanSQLiteTodo(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTodoNotFound
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return todo, nil
+}
+
+func (r *SQLiteTodoRepository) GetAll(ctx context.Context, ownerID string, opts models.TodoListOptions) (*models.TodoPage, error) {
+	after, err := decodeKeysetCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := pageLimit(opts)
+	query, args, err := sqlListQuery(ownerID, opts, after, limit,
+		func(int) string { return "?" },
+		func(t time.Time) interface{} { return formatSortableTime(t) },
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer rows.Close()
+
+	todos := []models.Todo{}
+	for rows.Next() {
+		todo, err := scanSQLiteTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, *todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storageError(err)
+	}
+
+	return keysetPage(todos, opts, limit)
+}
+
+func (r *SQLiteTodoRepository) Update(ctx context.Context, todo *models.Todo, fields []TodoField) error {
+	return sqlUpdateTodo(ctx, r.db, todo, fields,
+		func(int) string { return "?" },
+		func(t time.Time) interface{} { return formatSortableTime(t) },
+	)
+}
+
+func (r *SQLiteTodoRepository) Delete(ctx context.Context, ownerID, id string, version int) error {
+	return sqlDeleteTodo(ctx, r.db, ownerID, id, version, func(int) string { return "?" })
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSQLiteTodo(row rowScanner) (*models.Todo, error) {
+	var todo models.Todo
+	var createdAt, updatedAt string
+	err := row.Scan(&todo.ID, &todo.OwnerID, &todo.Title, &todo.Description, &todo.Completed, &createdAt, &updatedAt, &todo.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	if todo.CreatedAt, err = time.Parse(sortableTimeFormat, createdAt); err != nil {
+		return nil, err
+	}
+	if todo.UpdatedAt, err = time.Parse(sortableTimeFormat, updatedAt); err != nil {
+		return nil, err
+	}
+
+	return &todo, nil
+}
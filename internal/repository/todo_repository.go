@@ -2,47 +2,109 @@ package repository
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
 
 	"echo-todo/pkg/models"
 )
 
+// TodoField names a field of a todo an update can change
+type TodoField string
+
+const (
+	TodoFieldTitle       TodoField = "title"
+	TodoFieldDescription TodoField = "description"
+	TodoFieldCompleted   TodoField = "completed"
+)
+
 type TodoRepository interface {
 	Create(ctx context.Context, todo *models.Todo) error
-	GetByID(ctx context.Context, id string) (*models.Todo, error)
-	GetAll(ctx context.Context) ([]models.Todo, error)
-	Update(ctx context.Context, todo *models.Todo) error
-	Delete(ctx context.Context, id string) error
+	// GetByID returns ErrTodoNotFound if the owner has no todo with id
+	GetByID(ctx context.Context, ownerID, id string) (*models.Todo, error)
+	GetAll(ctx context.Context, ownerID string, opts models.TodoListOptions) (*models.TodoPage, error)
+	// Update writes fields and the update time of todo if the stored version
+	// still equals todo.Version and increments todo.Version. It never creates
+	// a todo and returns ErrTodoNotFound or ErrVersionConflict otherwise.
+	Update(ctx context.Context, todo *models.Todo, fields []TodoField) error
+	// Delete removes the todo. It returns ErrTodoNotFound if there is none
+	// and ErrVersionConflict if a non-zero version does not match the stored
+	// version.
+	Delete(ctx context.Context, ownerID, id string, version int) error
+}
+
+// Pinger is implemented by repositories that can check their backing store
+// is reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// pingDynamoDBTable checks the table exists and accepts reads and writes
+func pingDynamoDBTable(ctx context.Context, client *dynamodb.Client, tableName string) error {
+	result, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		return err
+	}
+
+	switch status := result.Table.TableStatus; status {
+	case types.TableStatusActive, types.TableStatusUpdating:
+		return nil
+	default:
+		return fmt.Errorf("table %s is %s", tableName, strings.ToLower(string(status)))
+	}
 }
 
+// Attributes added to every DynamoDB item so the todos of an owner can be
+// listed through global secondary indexes instead of scanning the table.
+// The table itself is keyed by owner_id (partition) and id (sort).
+const (
+	dynamoListPKAttr   = "list_pk"
+	dynamoStatusPKAttr = "status_pk"
+)
+
 type DynamoDBTodoRepository struct {
 	client    *dynamodb.Client
 	tableName string
+	logger    *slog.Logger
 }
 
-func NewDynamoDBTodoRepository(tableName string) (*DynamoDBTodoRepository, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+func NewDynamoDBTodoRepository(tableName string, logger *slog.Logger, optFns ...func(*config.LoadOptions) error) (*DynamoDBTodoRepository, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO(), optFns...)
 	if err != nil {
-		log.Printf("unable to load SDK config, %v", err)
+		logger.Error("unable to load SDK config", "error", err)
 		return nil, err
 	}
+	// Trace every DynamoDB call as a child span of the request
+	otelaws.AppendMiddlewares(&cfg.APIOptions)
 
 	client := dynamodb.NewFromConfig(cfg)
 
 	return &DynamoDBTodoRepository{
 		client:    client,
 		tableName: tableName,
+		logger:    logger,
 	}, nil
 }
 
+// Ping checks the todos table is available
+func (r *DynamoDBTodoRepository) Ping(ctx context.Context) error {
+	return pingDynamoDBTable(ctx, r.client, r.tableName)
+}
+
 func (r *DynamoDBTodoRepository) Create(ctx context.Context, todo *models.Todo) error {
-	item, err := attributevalue.MarshalMap(todo)
+	item, err := marshalDynamoDBTodo(todo)
 	if err != nil {
 		return err
 	}
@@ -51,22 +113,20 @@ func (r *DynamoDBTodoRepository) Create(ctx context.Context, todo *models.Todo)
 		TableName: aws.String(r.tableName),
 		Item:      item,
 	})
-	return err
+	return storageError(err)
 }
 
-func (r *DynamoDBTodoRepository) GetByID(ctx context.Context, id string) (*models.Todo, error) {
+func (r *DynamoDBTodoRepository) GetByID(ctx context.Context, ownerID, id string) (*models.Todo, error) {
 	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(r.tableName),
-		Key: map[string]types.AttributeValue{
-			"id": &types.AttributeValueMemberS{Value: id},
-		},
+		Key:       dynamoTodoKey(ownerID, id),
 	})
 	if err != nil {
-		return nil, err
+		return nil, storageError(err)
 	}
 
 	if result.Item == nil {
-		return nil, nil
+		return nil, ErrTodoNotFound
 	}
 
 	var todo models.Todo
@@ -74,46 +134,365 @@ func (r *DynamoDBTodoRepository) GetByID(ctx context.Context, id string) (*model
 	if err != nil {
 		return nil, err
 	}
+	defaultDynamoDBTodoVersion(&todo)
 
 	return &todo, nil
 }
 
-func (r *DynamoDBTodoRepository) GetAll(ctx context.Context) ([]models.Todo, error) {
-	result, err := r.client.Scan(ctx, &dynamodb.ScanInput{
-		TableName: aws.String(r.tableName),
-	})
+func (r *DynamoDBTodoRepository) GetAll(ctx context.Context, ownerID string, opts models.TodoListOptions) (*models.TodoPage, error) {
+	index, keyCond, filter := dynamoListQuery(ownerID, opts)
+
+	startKey, err := decodeDynamoDBCursor(opts, ownerID, index)
 	if err != nil {
 		return nil, err
 	}
 
-	var todos []models.Todo
-	err = attributevalue.UnmarshalListOfMaps(result.Items, &todos)
+	builder := expression.NewBuilder().WithKeyCondition(keyCond)
+	if filter != nil {
+		builder = builder.WithFilter(*filter)
+	}
+	expr, err := builder.Build()
 	if err != nil {
 		return nil, err
 	}
 
-	return todos, nil
+	// A single Query stops at 1 MB and Limit counts items before the filter
+	// is applied, so keep reading until the page is full
+	limit := pageLimit(opts)
+	todos := []models.Todo{}
+	queries := 0
+	for {
+		queries++
+		result, err := r.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(r.tableName),
+			IndexName:                 aws.String(index.name),
+			KeyConditionExpression:    expr.KeyCondition(),
+			FilterExpression:          expr.Filter(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			ScanIndexForward:          aws.Bool(!opts.SortDesc),
+			Limit:                     aws.Int32(int32(limit - len(todos))),
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			return nil, storageError(err)
+		}
+
+		var items []models.Todo
+		err = attributevalue.UnmarshalListOfMaps(result.Items, &items)
+		if err != nil {
+			return nil, err
+		}
+		for i := range items {
+			defaultDynamoDBTodoVersion(&items[i])
+		}
+		todos = append(todos, items...)
+
+		startKey = result.LastEvaluatedKey
+		if len(startKey) == 0 || len(todos) >= limit {
+			break
+		}
+	}
+
+	r.logger.DebugContext(ctx, "listed todos", "index", index.name, "queries", queries, "count", len(todos))
+
+	page := &models.TodoPage{Todos: todos}
+	if len(startKey) > 0 {
+		page.NextCursor, err = encodeDynamoDBCursor(opts, index.name, startKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
 }
 
-func (r *DynamoDBTodoRepository) Update(ctx context.Context, todo *models.Todo) error {
-	item, err := attributevalue.MarshalMap(todo)
+func (r *DynamoDBTodoRepository) Update(ctx context.Context, todo *models.Todo, fields []TodoField) error {
+	update := expression.Set(expression.Name("updated_at"), expression.Value(formatSortableTime(todo.UpdatedAt))).
+		Set(expression.Name("version"), expression.Value(todo.Version+1))
+	for _, field := range fields {
+		switch field {
+		case TodoFieldTitle:
+			update = update.Set(expression.Name("title"), expression.Value(todo.Title))
+		case TodoFieldDescription:
+			update = update.Set(expression.Name("description"), expression.Value(todo.Description))
+		case TodoFieldCompleted:
+			// Moves the item to the index partition of its new status
+			update = update.Set(expression.Name("completed"), expression.Value(todo.Completed)).
+				Set(expression.Name(dynamoStatusPKAttr), expression.Value(dynamoStatusPartition(todo.OwnerID, todo.Completed)))
+		default:
+			return fmt.Errorf("cannot update todo field %q", field)
+		}
+	}
+
+	// Without attribute_exists a todo deleted in the meantime would be
+	// recreated with only the updated attributes
+	expr, err := expression.NewBuilder().
+		WithUpdate(update).
+		WithCondition(expression.AttributeExists(expression.Name("id")).And(dynamoVersionCondition(todo.Version))).
+		Build()
 	if err != nil {
 		return err
 	}
 
-	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(r.tableName),
-		Item:      item,
+	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                           aws.String(r.tableName),
+		Key:                                 dynamoTodoKey(todo.OwnerID, todo.ID),
+		UpdateExpression:                    expr.Update(),
+		ConditionExpression:                 expr.Condition(),
+		ExpressionAttributeNames:            expr.Names(),
+		ExpressionAttributeValues:           expr.Values(),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
 	})
-	return err
+	if err != nil {
+		return dynamoConditionError(err)
+	}
+
+	todo.Version++
+	return nil
 }
 
-func (r *DynamoDBTodoRepository) Delete(ctx context.Context, id string) error {
-	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
-		TableName: aws.String(r.tableName),
-		Key: map[string]types.AttributeValue{
-			"id": &types.AttributeValueMemberS{Value: id},
-		},
+func (r *DynamoDBTodoRepository) Delete(ctx context.Context, ownerID, id string, version int) error {
+	// Deleting a missing item succeeds unless it is conditioned on its id
+	cond := expression.AttributeExists(expression.Name("id"))
+	if version != 0 {
+		cond = cond.And(dynamoVersionCondition(version))
+	}
+	expr, err := expression.NewBuilder().WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                           aws.String(r.tableName),
+		Key:                                 dynamoTodoKey(ownerID, id),
+		ConditionExpression:                 expr.Condition(),
+		ExpressionAttributeNames:            expr.Names(),
+		ExpressionAttributeValues:           expr.Values(),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	return dynamoConditionError(err)
+}
+
+// dynamoVersionCondition matches items stored with the given version. Items
+// written before versioning have no version attribute and count as version 1.
+func dynamoVersionCondition(version int) expression.ConditionBuilder {
+	cond := expression.Name("version").Equal(expression.Value(version))
+	if version == 1 {
+		cond = expression.AttributeNotExists(expression.Name("version")).Or(cond)
+	}
+	return cond
+}
+
+// dynamoConditionError maps a failed condition check of a write returning
+// the old item on failure to ErrTodoNotFound if there was no item and to
+// ErrVersionConflict otherwise. Other errors are passed to storageError.
+func dynamoConditionError(err error) error {
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		if len(conditionFailed.Item) == 0 {
+			return ErrTodoNotFound
+		}
+		return ErrVersionConflict
+	}
+	return storageError(err)
+}
+
+// defaultDynamoDBTodoVersion sets the version of items written before
+// versioning
+func defaultDynamoDBTodoVersion(todo *models.Todo) {
+	if todo.Version == 0 {
+		todo.Version = 1
+	}
+}
+
+// marshalDynamoDBTodo converts todo into an item including the index keys.
+// Timestamps are stored fixed width so they sort correctly as index keys.
+func marshalDynamoDBTodo(todo *models.Todo) (map[string]types.AttributeValue, error) {
+	item, err := attributevalue.MarshalMapWithOptions(todo, func(o *attributevalue.EncoderOptions) {
+		o.EncodeTime = func(t time.Time) (types.AttributeValue, error) {
+			return &types.AttributeValueMemberS{Value: formatSortableTime(t)}, nil
+		}
 	})
-	return err
-}
\ No newline at end of file
+	if err != nil {
+		return nil, err
+	}
+
+	item[dynamoListPKAttr] = &types.AttributeValueMemberS{Value: todo.OwnerID}
+	item[dynamoStatusPKAttr] = &types.AttributeValueMemberS{Value: dynamoStatusPartition(todo.OwnerID, todo.Completed)}
+
+	return item, nil
+}
+
+// dynamoTodoKey is the primary key of a todo item
+func dynamoTodoKey(ownerID, id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"owner_id": &types.AttributeValueMemberS{Value: ownerID},
+		"id":       &types.AttributeValueMemberS{Value: id},
+	}
+}
+
+func dynamoStatusPartition(ownerID string, completed bool) string {
+	if completed {
+		return ownerID + "#completed"
+	}
+	return ownerID + "#open"
+}
+
+// dynamoListIndex is the GSI and partition a listing queries
+type dynamoListIndex struct {
+	name           string
+	partitionKey   string
+	partitionValue string
+	sortKey        string
+}
+
+// newDynamoListIndex names the GSI with the given partition and sort key
+func newDynamoListIndex(partitionKey, partitionValue string, sortKey models.TodoSortField) dynamoListIndex {
+	return dynamoListIndex{
+		name:           partitionKey + "-" + string(sortKey) + "-index",
+		partitionKey:   partitionKey,
+		partitionValue: partitionValue,
+		sortKey:        string(sortKey),
+	}
+}
+
+// dynamoListQuery picks the GSI serving opts and splits the filters into a
+// key condition on the index sort key and a filter on the remaining ones.
+// The indexes sort on the attribute alone, items with equal values come
+// back in an order DynamoDB does not define.
+func dynamoListQuery(ownerID string, opts models.TodoListOptions) (dynamoListIndex, expression.KeyConditionBuilder, *expression.ConditionBuilder) {
+	field := sortField(opts)
+	sortKey := string(field)
+	var filters []expression.ConditionBuilder
+
+	// Open or completed todos by date have their own partition
+	index := newDynamoListIndex(dynamoListPKAttr, ownerID, field)
+	if opts.Completed != nil {
+		if field == models.TodoSortTitle {
+			filters = append(filters, expression.Name("completed").Equal(expression.Value(*opts.Completed)))
+		} else {
+			index = newDynamoListIndex(dynamoStatusPKAttr, dynamoStatusPartition(ownerID, *opts.Completed), field)
+		}
+	}
+	keyCond := expression.Key(index.partitionKey).Equal(expression.Value(index.partitionValue))
+
+	createdAfter := func() expression.ConditionBuilder {
+		return expression.Name("created_at").GreaterThan(expression.Value(formatSortableTime(*opts.CreatedAfter)))
+	}
+	createdBefore := func() expression.ConditionBuilder {
+		return expression.Name("created_at").LessThan(expression.Value(formatSortableTime(*opts.CreatedBefore)))
+	}
+	updatedSince := func() expression.ConditionBuilder {
+		return expression.Name("updated_at").GreaterThanEqual(expression.Value(formatSortableTime(*opts.UpdatedSince)))
+	}
+
+	switch field {
+	case models.TodoSortCreatedAt:
+		key := expression.Key(sortKey)
+		switch {
+		case opts.CreatedAfter != nil && opts.CreatedBefore != nil:
+			// BETWEEN is inclusive, the filter drops the bounds themselves
+			keyCond = keyCond.And(key.Between(
+				expression.Value(formatSortableTime(*opts.CreatedAfter)),
+				expression.Value(formatSortableTime(*opts.CreatedBefore)),
+			))
+			filters = append(filters, createdAfter(), createdBefore())
+		case opts.CreatedAfter != nil:
+			keyCond = keyCond.And(key.GreaterThan(expression.Value(formatSortableTime(*opts.CreatedAfter))))
+		case opts.CreatedBefore != nil:
+			keyCond = keyCond.And(key.LessThan(expression.Value(formatSortableTime(*opts.CreatedBefore))))
+		}
+		if opts.UpdatedSince != nil {
+			filters = append(filters, updatedSince())
+		}
+	case models.TodoSortUpdatedAt:
+		if opts.UpdatedSince != nil {
+			keyCond = keyCond.And(expression.Key(sortKey).GreaterThanEqual(expression.Value(formatSortableTime(*opts.UpdatedSince))))
+		}
+		if opts.CreatedAfter != nil {
+			filters = append(filters, createdAfter())
+		}
+		if opts.CreatedBefore != nil {
+			filters = append(filters, createdBefore())
+		}
+	default:
+		if opts.CreatedAfter != nil {
+			filters = append(filters, createdAfter())
+		}
+		if opts.CreatedBefore != nil {
+			filters = append(filters, createdBefore())
+		}
+		if opts.UpdatedSince != nil {
+			filters = append(filters, updatedSince())
+		}
+	}
+
+	if len(filters) == 0 {
+		return index, keyCond, nil
+	}
+	filter := filters[0]
+	for _, f := range filters[1:] {
+		filter = filter.And(f)
+	}
+
+	return index, keyCond, &filter
+}
+
+// dynamoDBCursor ties a LastEvaluatedKey to the index and order it came from
+type dynamoDBCursor struct {
+	Index string                 `json:"index"`
+	Sort  string                 `json:"sort"`
+	Key   map[string]interface{} `json:"key"`
+}
+
+// encodeDynamoDBCursor turns a LastEvaluatedKey into an opaque cursor
+func encodeDynamoDBCursor(opts models.TodoListOptions, index string, key map[string]types.AttributeValue) (string, error) {
+	var values map[string]interface{}
+	if err := attributevalue.UnmarshalMap(key, &values); err != nil {
+		return "", err
+	}
+	return encodeCursor(dynamoDBCursor{Index: index, Sort: sortSpec(opts), Key: values})
+}
+
+// decodeDynamoDBCursor turns a cursor back into an ExclusiveStartKey. The
+// key must be one of index in the partition of ownerID, so a forged cursor
+// cannot start a listing in the todos of another owner or fail the query.
+func decodeDynamoDBCursor(opts models.TodoListOptions, ownerID string, index dynamoListIndex) (map[string]types.AttributeValue, error) {
+	if opts.Cursor == "" {
+		return nil, nil
+	}
+
+	var c dynamoDBCursor
+	if err := decodeCursor(opts.Cursor, &c); err != nil {
+		return nil, err
+	}
+	if c.Index != index.name || c.Sort != sortSpec(opts) {
+		return nil, ErrInvalidCursor
+	}
+
+	// A LastEvaluatedKey of a GSI holds its keys and those of the table
+	want := map[string]string{
+		index.partitionKey: index.partitionValue,
+		index.sortKey:      "",
+		"owner_id":         ownerID,
+		"id":               "",
+	}
+	if len(c.Key) != len(want) {
+		return nil, ErrInvalidCursor
+	}
+	for name, value := range c.Key {
+		s, ok := value.(string)
+		wantValue, known := want[name]
+		if !ok || !known || s == "" || (wantValue != "" && s != wantValue) {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	key, err := attributevalue.MarshalMap(c.Key)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return key, nil
+}
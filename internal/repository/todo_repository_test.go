@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"echo-todo/pkg/models"
+)
+
+// todoBackend opens a TodoRepository of one storage backend for a test
+type todoBackend struct {
+	name string
+	open func(t *testing.T) TodoRepository
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// todoBackends returns the backends the contract tests run against. Memory
+// and SQLite always run, PostgreSQL if POSTGRES_TEST_DSN is set. The shared
+// database is not cleaned up, every test writes under its own owner.
+// DynamoDB is not covered, it does not order ties by id.
+func todoBackends() []todoBackend {
+	backends := []todoBackend{
+		{name: "memory", open: func(t *testing.T) TodoRepository {
+			return NewMemoryTodoRepository()
+		}},
+		{name: "sqlite", open: func(t *testing.T) TodoRepository {
+			repo, err := NewSQLiteTodoRepository(context.Background(), filepath.Join(t.TempDir(), "todos.db"), testLogger())
+			if err != nil {
+				t.Fatalf("NewSQLiteTodoRepository() error = %v", err)
+			}
+			t.Cleanup(func() { repo.Close() })
+			return repo
+		}},
+	}
+	if dsn := os.Getenv("POSTGRES_TEST_DSN"); dsn != "" {
+		backends = append(backends, todoBackend{name: "postgres", open: func(t *testing.T) TodoRepository {
+			return openPostgres(t, dsn)
+		}})
+	}
+	return backends
+}
+
+func openPostgres(t *testing.T, dsn string) *PostgresTodoRepository {
+	t.Helper()
+	repo, err := NewPostgresTodoRepository(context.Background(), dsn, PostgresPoolOptions{
+		MaxOpenConns: 4,
+		MaxIdleConns: 2,
+	}, testLogger())
+	if err != nil {
+		t.Fatalf("NewPostgresTodoRepository() error = %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+// forEachBackend runs test on every backend with an owner no other test
+// writes to
+func forEachBackend(t *testing.T, test func(t *testing.T, repo TodoRepository, ownerID string)) {
+	for _, backend := range todoBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			test(t, backend.open(t), fmt.Sprintf("owner%d", time.Now().UnixNano()))
+		})
+	}
+}
+
+// testTime is the base of the timestamps of test todos, PostgreSQL keeps
+// microseconds
+var testTime = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func minutes(n int) time.Time {
+	return testTime.Add(time.Duration(n) * time.Minute)
+}
+
+// createTestTodo stores todo with version 1
+func createTestTodo(t *testing.T, repo TodoRepository, todo models.Todo) models.Todo {
+	t.Helper()
+	todo.Version = 1
+	if err := repo.Create(context.Background(), &todo); err != nil {
+		t.Fatalf("Create(%s) error = %v", todo.ID, err)
+	}
+	return todo
+}
+
+func TestUpdateVersion(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo TodoRepository, ownerID string) {
+		ctx := context.Background()
+		stored := createTestTodo(t, repo, models.Todo{
+			ID: "t1", OwnerID: ownerID, Title: "a", Description: "d", CreatedAt: minutes(0), UpdatedAt: minutes(0),
+		})
+
+		// Only the given fields are written
+		todo := stored
+		todo.Title, todo.Description, todo.UpdatedAt = "b", "ignored", minutes(1)
+		if err := repo.Update(ctx, &todo, []TodoField{TodoFieldTitle}); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		if todo.Version != 2 {
+			t.Errorf("version after Update = %d, want 2", todo.Version)
+		}
+		got, err := repo.GetByID(ctx, ownerID, todo.ID)
+		if err != nil {
+			t.Fatalf("GetByID() error = %v", err)
+		}
+		if got.Title != "b" || got.Description != "d" || got.Version != 2 || !got.UpdatedAt.Equal(minutes(1)) {
+			t.Errorf("stored todo = %+v", got)
+		}
+
+		// A write based on version 1 lost against the one above
+		stale := stored
+		stale.Completed = true
+		if err := repo.Update(ctx, &stale, []TodoField{TodoFieldCompleted}); !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("Update(stale) error = %v, want ErrVersionConflict", err)
+		}
+		if got, _ := repo.GetByID(ctx, ownerID, todo.ID); got.Completed || got.Version != 2 {
+			t.Errorf("stale update was written: %+v", got)
+		}
+
+		// Todos of other owners cannot be updated
+		other := todo
+		other.OwnerID = ownerID + "other"
+		if err := repo.Update(ctx, &other, []TodoField{TodoFieldTitle}); !errors.Is(err, ErrTodoNotFound) {
+			t.Errorf("Update(other owner) error = %v, want ErrTodoNotFound", err)
+		}
+		if _, err := repo.GetByID(ctx, other.OwnerID, todo.ID); !errors.Is(err, ErrTodoNotFound) {
+			t.Errorf("GetByID(other owner) error = %v, want ErrTodoNotFound", err)
+		}
+	})
+}
+
+func TestDeleteVersion(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo TodoRepository, ownerID string) {
+		ctx := context.Background()
+		todo := createTestTodo(t, repo, models.Todo{
+			ID: "t1", OwnerID: ownerID, Title: "a", CreatedAt: minutes(0), UpdatedAt: minutes(0),
+		})
+
+		// A todo of another owner with the same id is not affected
+		other := createTestTodo(t, repo, models.Todo{
+			ID: todo.ID, OwnerID: ownerID + "other", Title: "b", CreatedAt: minutes(0), UpdatedAt: minutes(0),
+		})
+
+		if err := repo.Delete(ctx, ownerID, todo.ID, 2); !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("Delete(stale) error = %v, want ErrVersionConflict", err)
+		}
+		if err := repo.Delete(ctx, ownerID+"none", todo.ID, 0); !errors.Is(err, ErrTodoNotFound) {
+			t.Fatalf("Delete(owner without the todo) error = %v, want ErrTodoNotFound", err)
+		}
+		if err := repo.Delete(ctx, ownerID, todo.ID, 1); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if err := repo.Delete(ctx, ownerID, todo.ID, 0); !errors.Is(err, ErrTodoNotFound) {
+			t.Errorf("Delete(deleted) error = %v, want ErrTodoNotFound", err)
+		}
+		if got, err := repo.GetByID(ctx, other.OwnerID, other.ID); err != nil || got.Title != "b" {
+			t.Errorf("GetByID(other owner) = %+v, %v", got, err)
+		}
+
+		// An update racing the delete must not bring the todo back
+		todo.Title = "b"
+		if err := repo.Update(ctx, &todo, []TodoField{TodoFieldTitle}); !errors.Is(err, ErrTodoNotFound) {
+			t.Fatalf("Update(deleted) error = %v, want ErrTodoNotFound", err)
+		}
+		if _, err := repo.GetByID(ctx, ownerID, todo.ID); !errors.Is(err, ErrTodoNotFound) {
+			t.Errorf("GetByID(deleted) error = %v, want ErrTodoNotFound", err)
+		}
+		page, err := repo.GetAll(ctx, ownerID, models.TodoListOptions{Limit: 10})
+		if err != nil {
+			t.Fatalf("GetAll() error = %v", err)
+		}
+		if len(page.Todos) != 0 {
+			t.Errorf("GetAll() after delete = %+v", page.Todos)
+		}
+	})
+}
+
+// TestUpdateConcurrent checks that of concurrent writes based on the same
+// version exactly one wins
+func TestUpdateConcurrent(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo TodoRepository, ownerID string) {
+		ctx := context.Background()
+		todo := createTestTodo(t, repo, models.Todo{
+			ID: "t1", OwnerID: ownerID, Title: "a", CreatedAt: minutes(0), UpdatedAt: minutes(0),
+		})
+
+		const writers = 8
+		errs := make(chan error, writers)
+		for i := 0; i < writers; i++ {
+			go func(i int) {
+				update := todo
+				update.Title = fmt.Sprintf("writer %d", i)
+				errs <- repo.Update(ctx, &update, []TodoField{TodoFieldTitle})
+			}(i)
+		}
+		won := 0
+		for i := 0; i < writers; i++ {
+			switch err := <-errs; {
+			case err == nil:
+				won++
+			case !errors.Is(err, ErrVersionConflict):
+				t.Errorf("Update() error = %v, want nil or ErrVersionConflict", err)
+			}
+		}
+		if won != 1 {
+			t.Errorf("%d concurrent updates won, want 1", won)
+		}
+		if got, _ := repo.GetByID(ctx, ownerID, todo.ID); got.Version != 2 {
+			t.Errorf("version = %d, want 2", got.Version)
+		}
+	})
+}
@@ -0,0 +1,18 @@
+package requestid
+
+import (
+	"context"
+)
+
+type contextKey struct{}
+
+// WithID returns a copy of ctx carrying the request id
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request id stored by WithID, empty if none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
@@ -0,0 +1,106 @@
+package search
+
+import (
+	"html"
+	"strings"
+)
+
+// Markers wrapped around matched terms by Highlight
+const (
+	HighlightStart = "<mark>"
+	HighlightEnd   = "</mark>"
+)
+
+// Highlight returns text with the words matching query wrapped in
+// HighlightStart and HighlightEnd. The rest of the text is HTML escaped so
+// the result can be rendered as is. When maxRunes is positive the text is
+// cut down to a snippet of about that many characters around the first match.
+func Highlight(text, query string, maxRunes int) string {
+	queryTerms := Terms(query)
+
+	// Collect the byte ranges of matching tokens, merging overlaps
+	var spans [][2]int
+	for _, token := range Tokenize(text) {
+		if !matchesAny(token.Term, queryTerms) {
+			continue
+		}
+		if n := len(spans); n > 0 && token.Start <= spans[n-1][1] {
+			if token.End > spans[n-1][1] {
+				spans[n-1][1] = token.End
+			}
+			continue
+		}
+		spans = append(spans, [2]int{token.Start, token.End})
+	}
+
+	start, end := 0, len(text)
+	if maxRunes > 0 {
+		focus := 0
+		if len(spans) > 0 {
+			focus = spans[0][0]
+		}
+		start, end = snippetBounds(text, focus, maxRunes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, span := range spans {
+		if span[1] <= start || span[0] >= end {
+			continue
+		}
+		spanStart, spanEnd := max(span[0], start), min(span[1], end)
+		b.WriteString(html.EscapeString(text[pos:spanStart]))
+		b.WriteString(HighlightStart)
+		b.WriteString(html.EscapeString(text[spanStart:spanEnd]))
+		b.WriteString(HighlightEnd)
+		pos = spanEnd
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+
+	return b.String()
+}
+
+func matchesAny(term string, queryTerms []string) bool {
+	for _, q := range queryTerms {
+		if strings.HasPrefix(term, q) {
+			return true
+		}
+	}
+	return false
+}
+
+// snippetBounds returns byte offsets of a window of at most maxRunes
+// characters starting a little before the byte offset focus
+func snippetBounds(text string, focus, maxRunes int) (int, int) {
+	var offsets []int
+	focusIndex := 0
+	for i := range text {
+		if i <= focus {
+			focusIndex = len(offsets)
+		}
+		offsets = append(offsets, i)
+	}
+	if len(offsets) <= maxRunes {
+		return 0, len(text)
+	}
+	offsets = append(offsets, len(text))
+
+	// Show some context before the match
+	first := focusIndex - maxRunes/4
+	if first < 0 {
+		first = 0
+	}
+	last := first + maxRunes
+	if last > len(offsets)-1 {
+		last = len(offsets) - 1
+		first = last - maxRunes
+	}
+
+	return offsets[first], offsets[last]
+}
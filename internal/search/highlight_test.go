@@ -0,0 +1,36 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		query    string
+		maxRunes int
+		want     string
+	}{
+		{"exact", "Buy milk", "milk", 0, "Buy <mark>milk</mark>"},
+		{"whole word of a prefix", "Milkshake", "milk", 0, "<mark>Milkshake</mark>"},
+		{"every term", "buy milk and eggs", "eggs buy", 0, "<mark>buy</mark> milk and <mark>eggs</mark>"},
+		{"html is escaped", `<b>milk</b> & "eggs"`, "milk", 0, "&lt;b&gt;<mark>milk</mark>&lt;/b&gt; &amp; &#34;eggs&#34;"},
+		{"no match", "Buy bread", "milk", 0, "Buy bread"},
+		{"overlapping bigrams merge", "東京タワーに行く", "タワー", 0, "東京<mark>タワー</mark>に行く"},
+		{"short text is not cut", "Buy milk", "milk", 20, "Buy <mark>milk</mark>"},
+		{"snippet around the match", strings.Repeat("a ", 20) + "milk" + strings.Repeat(" b", 20), "milk", 12,
+			"… a <mark>milk</mark> b b …"},
+		{"snippet at the start", "milk" + strings.Repeat(" b", 20), "milk", 8, "<mark>milk</mark> b b…"},
+		{"snippet without match", strings.Repeat("a", 20), "milk", 5, "aaaaa…"},
+		{"snippet counts characters", strings.Repeat("日本", 10), "牛乳", 4, "日本日本…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Highlight(tt.text, tt.query, tt.maxRunes); got != tt.want {
+				t.Errorf("Highlight(%q, %q, %d) = %q, want %q", tt.text, tt.query, tt.maxRunes, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+// Package search implements a small in-process full-text index with
+// prefix matching and relevance ranking.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// prefixWeight scales the score of terms matched only by prefix
+const prefixWeight = 0.5
+
+// Field is a piece of text indexed for a document. Matches in fields with
+// a higher Boost rank higher.
+type Field struct {
+	Text  string
+	Boost float64
+}
+
+// Hit is a matching document and its relevance score
+type Hit struct {
+	ID    string
+	Score float64
+}
+
+// Index is a concurrency-safe inverted index from terms to documents
+type Index struct {
+	mu sync.RWMutex
+	// postings maps a term to the boosted term frequency per document
+	postings map[string]map[string]float64
+	// docTerms lists the terms of each document so it can be removed
+	docTerms map[string][]string
+	// vocabulary is the sorted list of terms, rebuilt lazily for prefix lookups
+	vocabulary []string
+	dirty      bool
+}
+
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[string]float64),
+		docTerms: make(map[string][]string),
+	}
+}
+
+// Add indexes a document, replacing any previous version with the same id
+func (ix *Index) Add(id string, fields ...Field) {
+	freqs := make(map[string]float64)
+	for _, field := range fields {
+		boost := field.Boost
+		if boost == 0 {
+			boost = 1
+		}
+		for _, token := range Tokenize(field.Text) {
+			freqs[token.Term] += boost
+		}
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(id)
+	terms := make([]string, 0, len(freqs))
+	for term, freq := range freqs {
+		docs, ok := ix.postings[term]
+		if !ok {
+			docs = make(map[string]float64)
+			ix.postings[term] = docs
+			ix.dirty = true
+		}
+		docs[id] = freq
+		terms = append(terms, term)
+	}
+	ix.docTerms[id] = terms
+}
+
+// Remove drops a document from the index
+func (ix *Index) Remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(id)
+}
+
+func (ix *Index) remove(id string) {
+	for _, term := range ix.docTerms[id] {
+		docs := ix.postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(ix.postings, term)
+			ix.dirty = true
+		}
+	}
+	delete(ix.docTerms, id)
+}
+
+// Len returns the number of indexed documents
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	return len(ix.docTerms)
+}
+
+// Search returns up to limit documents containing every term of query,
+// best match first. A query term matches index terms equal to it or, with
+// a lower weight, starting with it.
+func (ix *Index) Search(query string, limit int) []Hit {
+	queryTerms := Terms(query)
+	if len(queryTerms) == 0 {
+		return nil
+	}
+
+	// The vocabulary is rebuilt and read under the same lock, so an Add in
+	// between cannot leave it out of date for this search
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if ix.dirty {
+		ix.vocabulary = ix.vocabulary[:0]
+		for term := range ix.postings {
+			ix.vocabulary = append(ix.vocabulary, term)
+		}
+		sort.Strings(ix.vocabulary)
+		ix.dirty = false
+	}
+
+	total := float64(len(ix.docTerms))
+	var scores map[string]float64
+	for _, queryTerm := range queryTerms {
+		termScores := make(map[string]float64)
+		for _, term := range ix.expand(queryTerm) {
+			weight := 1.0
+			if term != queryTerm {
+				weight = prefixWeight
+			}
+			docs := ix.postings[term]
+			idf := math.Log(1 + total/float64(len(docs)))
+			for id, freq := range docs {
+				termScores[id] += weight * (1 + math.Log(freq)) * idf
+			}
+		}
+
+		// Every query term must match
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for id := range scores {
+			if s, ok := termScores[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score == hits[j].Score {
+			return hits[i].ID < hits[j].ID
+		}
+		return hits[i].Score > hits[j].Score
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return hits
+}
+
+// expand returns the indexed terms starting with prefix
+func (ix *Index) expand(prefix string) []string {
+	i := sort.SearchStrings(ix.vocabulary, prefix)
+	var terms []string
+	for ; i < len(ix.vocabulary) && strings.HasPrefix(ix.vocabulary[i], prefix); i++ {
+		terms = append(terms, ix.vocabulary[i])
+	}
+	return terms
+}
@@ -0,0 +1,130 @@
+package search
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func hitIDs(hits []Hit) []string {
+	ids := []string{}
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func TestIndexSearch(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("title", Field{Text: "Buy milk", Boost: 2}, Field{Text: "at the shop"})
+	ix.Add("description", Field{Text: "Shopping", Boost: 2}, Field{Text: "buy milk and eggs"})
+	ix.Add("prefix", Field{Text: "Milkshake recipe", Boost: 2})
+	ix.Add("repeated", Field{Text: "milk"}, Field{Text: "milk milk milk"})
+	ix.Add("other", Field{Text: "Walk the dog", Boost: 2})
+
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []string
+	}{
+		// Repeated terms rank above single ones, exact matches above prefix
+		// matches and title matches, even by prefix, above description ones
+		{"ranking", "milk", 0, []string{"repeated", "title", "prefix", "description"}},
+		{"every term must match", "buy milk", 0, []string{"title", "description"}},
+		{"prefix", "sho", 0, []string{"description", "title"}},
+		{"case insensitive", "MILKSHAKE", 0, []string{"prefix"}},
+		{"limit", "milk", 2, []string{"repeated", "title"}},
+		{"no match", "cat", 0, []string{}},
+		{"one term without match", "milk cat", 0, []string{}},
+		{"no terms", "!!", 0, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hitIDs(ix.Search(tt.query, tt.limit)); !slices.Equal(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexRanking(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("b", Field{Text: "milk"})
+	ix.Add("a", Field{Text: "milk"})
+	ix.Add("rare", Field{Text: "milk tea"})
+	ix.Add("common", Field{Text: "milk bread"})
+	ix.Add("c", Field{Text: "bread"})
+
+	hits := ix.Search("milk", 0)
+	scores := make(map[string]float64)
+	for _, hit := range hits {
+		scores[hit.ID] = hit.Score
+	}
+	// Equal scores are ordered by id
+	if scores["a"] != scores["b"] || !slices.Equal(hitIDs(hits), []string{"a", "b", "common", "rare"}) {
+		t.Errorf("Search(milk) = %v", hits)
+	}
+
+	// Rarer terms weigh more
+	tea, bread := ix.Search("milk tea", 0), ix.Search("milk bread", 0)
+	if len(tea) != 1 || len(bread) != 1 || tea[0].Score <= bread[0].Score {
+		t.Errorf("score of rare term %v, of common term %v", tea, bread)
+	}
+}
+
+func TestIndexUpdate(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("1", Field{Text: "buy milk"})
+	ix.Add("2", Field{Text: "buy eggs"})
+
+	// Adding again replaces the document
+	ix.Add("1", Field{Text: "buy bread"})
+	if got := hitIDs(ix.Search("milk", 0)); len(got) != 0 {
+		t.Errorf("Search(milk) after replace = %v", got)
+	}
+	if got := hitIDs(ix.Search("bread", 0)); !slices.Equal(got, []string{"1"}) {
+		t.Errorf("Search(bread) = %v", got)
+	}
+
+	ix.Remove("2")
+	if got := hitIDs(ix.Search("buy", 0)); !slices.Equal(got, []string{"1"}) {
+		t.Errorf("Search(buy) after remove = %v", got)
+	}
+	if ix.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", ix.Len())
+	}
+
+	// Terms added after a search are found by prefix
+	ix.Add("3", Field{Text: "bravo"})
+	if got := hitIDs(ix.Search("bra", 0)); !slices.Equal(got, []string{"3"}) {
+		t.Errorf("Search(bra) = %v", got)
+	}
+}
+
+// TestIndexConcurrent is meant for the race detector
+func TestIndexConcurrent(t *testing.T) {
+	ix := NewIndex()
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				ix.Add(fmt.Sprintf("%d-%d", w, i), Field{Text: fmt.Sprintf("todo%d number%d", w, i)})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				ix.Search("todo", 10)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(ix.Search("number", 0)); got != 400 {
+		t.Errorf("Search(number) found %d documents, want 400", got)
+	}
+}
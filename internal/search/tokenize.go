@@ -0,0 +1,95 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Token is a normalized term and its byte offsets in the source text
+type Token struct {
+	Term  string
+	Start int
+	End   int
+}
+
+// Tokenize splits text into lower-cased terms. Letters and digits form
+// words; Japanese and Chinese text, which has no spaces, is split into
+// overlapping two character terms (bigrams).
+func Tokenize(text string) []Token {
+	var tokens []Token
+
+	start := -1
+	cjk := false
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		if cjk {
+			tokens = append(tokens, bigrams(text, start, end)...)
+		} else {
+			tokens = append(tokens, Token{Term: strings.ToLower(text[start:end]), Start: start, End: end})
+		}
+		start = -1
+	}
+
+	for i, r := range text {
+		switch {
+		case isCJK(r):
+			if start >= 0 && !cjk {
+				flush(i)
+			}
+			if start < 0 {
+				start, cjk = i, true
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			if start >= 0 && cjk {
+				flush(i)
+			}
+			if start < 0 {
+				start, cjk = i, false
+			}
+		default:
+			flush(i)
+		}
+	}
+	flush(len(text))
+
+	return tokens
+}
+
+// Terms returns the distinct terms of text in order of appearance
+func Terms(text string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, token := range Tokenize(text) {
+		if !seen[token.Term] {
+			seen[token.Term] = true
+			terms = append(terms, token.Term)
+		}
+	}
+	return terms
+}
+
+// bigrams splits the CJK run text[start:end] into overlapping pairs of
+// characters, a single character run becomes one term
+func bigrams(text string, start, end int) []Token {
+	var offsets []int
+	for i := range text[start:end] {
+		offsets = append(offsets, start+i)
+	}
+	offsets = append(offsets, end)
+
+	if len(offsets) == 2 {
+		return []Token{{Term: text[start:end], Start: start, End: end}}
+	}
+
+	tokens := make([]Token, 0, len(offsets)-2)
+	for i := 0; i+2 < len(offsets); i++ {
+		tokens = append(tokens, Token{Term: text[offsets[i]:offsets[i+2]], Start: offsets[i], End: offsets[i+2]})
+	}
+	return tokens
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) || r == 'ー'
+}
@@ -0,0 +1,48 @@
+package search
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Token
+	}{
+		{"words are lower-cased", "Buy MILK, eggs & bread!", []Token{
+			{"buy", 0, 3}, {"milk", 4, 8}, {"eggs", 10, 14}, {"bread", 17, 22},
+		}},
+		{"digits", "v1.2 x86_64", []Token{{"v1", 0, 2}, {"2", 3, 4}, {"x86", 5, 8}, {"64", 9, 11}}},
+		{"accents", "Café", []Token{{"café", 0, 5}}},
+		{"combining marks stay in the word", "e\u0301te\u0301 x", []Token{{"e\u0301te\u0301", 0, 7}, {"x", 8, 9}}},
+		{"japanese bigrams", "東京タワー", []Token{{"東京", 0, 6}, {"京タ", 3, 9}, {"タワ", 6, 12}, {"ワー", 9, 15}}},
+		{"single CJK character", "日", []Token{{"日", 0, 3}}},
+		{"mixed scripts", "Go言語で", []Token{{"go", 0, 2}, {"言語", 2, 8}, {"語で", 5, 11}}},
+		{"CJK runs split by punctuation", "牛乳、卵", []Token{{"牛乳", 0, 6}, {"卵", 9, 12}}},
+		{"no words", " -- !? ", nil},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Tokenize(tt.text)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Tokenize(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+			for _, token := range got {
+				if token.Term != strings.ToLower(tt.text[token.Start:token.End]) {
+					t.Errorf("token %v does not match its offsets", token)
+				}
+			}
+		})
+	}
+}
+
+func TestTerms(t *testing.T) {
+	got := Terms("Milk milk MILK eggs milk")
+	if want := []string{"milk", "eggs"}; !slices.Equal(got, want) {
+		t.Errorf("Terms() = %v, want %v", got, want)
+	}
+}
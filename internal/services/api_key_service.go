@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"echo-todo/internal/apperror"
+	"echo-todo/internal/auth"
+	"echo-todo/internal/repository"
+	"echo-todo/pkg/models"
+)
+
+var (
+	ErrAPIKeyNotFound      = repository.ErrAPIKeyNotFound
+	ErrAPIKeyRevoked       = repository.ErrAPIKeyRevoked
+	ErrInvalidAPIKey       = apperror.Unauthorized("invalid api key")
+	ErrInvalidAPIKeyScopes = apperror.Validation("invalid api key scopes")
+	ErrAPIKeyScopeNotHeld  = apperror.Forbidden("cannot grant a scope the caller does not hold")
+	ErrAPIKeyNotManageable = apperror.Forbidden("cannot manage a key with a scope the caller does not hold")
+)
+
+const (
+	// APIKeyPrefix starts every API key so keys are recognizable, e.g. by
+	// secret scanners
+	APIKeyPrefix = "etk_"
+	// apiKeyDisplayLength is the length of the key prefix kept in plain text
+	apiKeyDisplayLength = len(APIKeyPrefix) + 8
+	// apiKeyLastUsedInterval limits how often the last use of a key is written
+	apiKeyLastUsedInterval = time.Minute
+)
+
+type APIKeyService interface {
+	// CreateAPIKey creates a key owned by caller. It returns
+	// ErrAPIKeyScopeNotHeld if a requested scope was not granted to caller,
+	// so a limited key cannot create a more powerful one.
+	CreateAPIKey(ctx context.Context, caller *auth.User, req *models.CreateAPIKeyRequest) (*models.CreatedAPIKey, error)
+	ListAPIKeys(ctx context.Context, ownerID string) ([]models.APIKey, error)
+	// RotateAPIKey and RevokeAPIKey change a key of caller. They return
+	// ErrAPIKeyNotManageable unless caller holds every scope of the key, so
+	// a limited key cannot obtain the secret of a more powerful one.
+	RotateAPIKey(ctx context.Context, caller *auth.User, id string) (*models.CreatedAPIKey, error)
+	RevokeAPIKey(ctx context.Context, caller *auth.User, id string) (*models.APIKey, error)
+	// AuthenticateAPIKey returns the active key matching the secret key and
+	// records its use
+	AuthenticateAPIKey(ctx context.Context, key string) (*models.APIKey, error)
+}
+
+type apiKeyService struct {
+	apiKeyRepo repository.APIKeyRepository
+	logger     *slog.Logger
+}
+
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository, logger *slog.Logger) APIKeyService {
+	return &apiKeyService{
+		apiKeyRepo: apiKeyRepo,
+		logger:     logger,
+	}
+}
+
+func (s *apiKeyService) CreateAPIKey(ctx context.Context, caller *auth.User, req *models.CreateAPIKeyRequest) (*models.CreatedAPIKey, error) {
+	if err := validateAPIKeyScopes(req.Scopes); err != nil {
+		return nil, err
+	}
+	for _, scope := range req.Scopes {
+		if !caller.HasScope(scope) {
+			return nil, fmt.Errorf("%w: %s", ErrAPIKeyScopeNotHeld, scope)
+		}
+	}
+
+	secret, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key := models.APIKey{
+		ID:        generateID(),
+		OwnerID:   caller.ID,
+		Name:      req.Name,
+		Prefix:    secret[:apiKeyDisplayLength],
+		KeyHash:   hashAPIKey(secret),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(req.Scopes))),
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.apiKeyRepo.Create(ctx, &key); err != nil {
+		return nil, err
+	}
+	s.logger.InfoContext(ctx, "created api key", "api_key_id", key.ID, "scopes", key.Scopes)
+
+	return &models.CreatedAPIKey{APIKey: key, Key: secret}, nil
+}
+
+func (s *apiKeyService) ListAPIKeys(ctx context.Context, ownerID string) ([]models.APIKey, error) {
+	return s.apiKeyRepo.GetAll(ctx, ownerID)
+}
+
+func (s *apiKeyService) RotateAPIKey(ctx context.Context, caller *auth.User, id string) (*models.CreatedAPIKey, error) {
+	ownerID := caller.ID
+	key, err := s.apiKeyRepo.GetByID(ctx, ownerID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkManageable(caller, key); err != nil {
+		return nil, err
+	}
+	if key.Revoked() {
+		return nil, ErrAPIKeyRevoked
+	}
+
+	// The old secret stops working immediately
+	secret, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	key.Prefix = secret[:apiKeyDisplayLength]
+	key.KeyHash = hashAPIKey(secret)
+	key.RotatedAt = &now
+
+	// Fails with ErrAPIKeyRevoked if the key was revoked since it was read
+	if err := s.apiKeyRepo.Rotate(ctx, ownerID, id, key.Prefix, key.KeyHash, now); err != nil {
+		return nil, err
+	}
+	s.logger.InfoContext(ctx, "rotated api key", "api_key_id", key.ID)
+
+	return &models.CreatedAPIKey{APIKey: *key, Key: secret}, nil
+}
+
+func (s *apiKeyService) RevokeAPIKey(ctx context.Context, caller *auth.User, id string) (*models.APIKey, error) {
+	ownerID := caller.ID
+	key, err := s.apiKeyRepo.GetByID(ctx, ownerID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkManageable(caller, key); err != nil {
+		return nil, err
+	}
+
+	// Revoking twice keeps the original revocation time
+	if key.Revoked() {
+		return key, nil
+	}
+
+	now := time.Now()
+	key.RevokedAt = &now
+	err = s.apiKeyRepo.Revoke(ctx, ownerID, id, now)
+	if errors.Is(err, ErrAPIKeyRevoked) {
+		// Revoked concurrently, return the key with that revocation time
+		return s.apiKeyRepo.GetByID(ctx, ownerID, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.logger.InfoContext(ctx, "revoked api key", "api_key_id", key.ID)
+
+	return key, nil
+}
+
+func (s *apiKeyService) AuthenticateAPIKey(ctx context.Context, secret string) (*models.APIKey, error) {
+	if !strings.HasPrefix(secret, APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.apiKeyRepo.GetByHash(ctx, hashAPIKey(secret))
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key.Revoked() {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// Record the use at most once per interval to keep writes off the hot path
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyLastUsedInterval {
+		if err := s.apiKeyRepo.UpdateLastUsed(ctx, key.OwnerID, key.ID, now); err != nil {
+			s.logger.WarnContext(ctx, "failed to record use of api key", "api_key_id", key.ID, "error", err)
+		} else {
+			key.LastUsedAt = &now
+		}
+	}
+
+	return key, nil
+}
+
+// checkManageable returns ErrAPIKeyNotManageable unless caller holds every
+// scope of key. Keys without scopes grant every scope, so only callers with
+// every scope may manage them.
+func checkManageable(caller *auth.User, key *models.APIKey) error {
+	if key.Scopes == nil {
+		if caller.Scopes != nil {
+			return fmt.Errorf("%w: the key grants every scope", ErrAPIKeyNotManageable)
+		}
+		return nil
+	}
+	for _, scope := range key.Scopes {
+		if !caller.HasScope(scope) {
+			return fmt.Errorf("%w: %s", ErrAPIKeyNotManageable, scope)
+		}
+	}
+	return nil
+}
+
+func validateAPIKeyScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKeyScopes)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(models.APIKeyScopes, scope) {
+			return fmt.Errorf("%w: unknown scope %q, expected one of %s",
+				ErrInvalidAPIKeyScopes, scope, strings.Join(models.APIKeyScopes, ", "))
+		}
+	}
+	return nil
+}
+
+// generateAPIKey returns a new secret key with 256 bits of entropy
+func generateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashAPIKey returns the stored form of a secret key. Keys are random, so
+// a fast unsalted hash is enough to make a leaked table useless.
+func hashAPIKey(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
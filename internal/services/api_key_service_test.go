@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"echo-todo/internal/auth"
+	"echo-todo/internal/repository"
+	"echo-todo/pkg/models"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func newTestAPIKeyService() (APIKeyService, *repository.MemoryAPIKeyRepository) {
+	repo := repository.NewMemoryAPIKeyRepository()
+	return NewAPIKeyService(repo, testLogger()), repo
+}
+
+// allScopes is a caller holding every scope, e.g. a logged in user
+var allScopes = &auth.User{ID: "alice"}
+
+func scopedCaller(scopes ...string) *auth.User {
+	return &auth.User{ID: "alice", Scopes: scopes}
+}
+
+func createTestKey(t *testing.T, service APIKeyService, scopes ...string) *models.CreatedAPIKey {
+	t.Helper()
+	key, err := service.CreateAPIKey(context.Background(), allScopes, &models.CreateAPIKeyRequest{Name: "test", Scopes: scopes})
+	if err != nil {
+		t.Fatalf("CreateAPIKey(%v) error = %v", scopes, err)
+	}
+	return key
+}
+
+func TestCreateAPIKeyScopes(t *testing.T) {
+	service, _ := newTestAPIKeyService()
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		caller *auth.User
+		scopes []string
+		want   error
+	}{
+		{"all scopes caller", allScopes, []string{models.ScopeTodosWrite, models.ScopeAPIKeysManage}, nil},
+		{"held scopes", scopedCaller(models.ScopeAPIKeysManage, models.ScopeTodosRead), []string{models.ScopeTodosRead}, nil},
+		{"scope not held", scopedCaller(models.ScopeAPIKeysManage), []string{models.ScopeTodosWrite}, ErrAPIKeyScopeNotHeld},
+		{"unknown scope", allScopes, []string{"admin"}, ErrInvalidAPIKeyScopes},
+		{"no scopes", allScopes, nil, ErrInvalidAPIKeyScopes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := service.CreateAPIKey(ctx, tt.caller, &models.CreateAPIKeyRequest{Name: "k", Scopes: tt.scopes})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("CreateAPIKey() error = %v, want %v", err, tt.want)
+			}
+			if err == nil && (key.OwnerID != "alice" || key.Key == "") {
+				t.Errorf("created key = %+v", key)
+			}
+		})
+	}
+}
+
+// TestManageAPIKeyScopes checks a key can only be rotated or revoked by a
+// caller holding every scope of it
+func TestManageAPIKeyScopes(t *testing.T) {
+	manageOnly := scopedCaller(models.ScopeAPIKeysManage)
+	listedScopes := scopedCaller(models.APIKeyScopes...)
+
+	tests := []struct {
+		name   string
+		caller *auth.User
+		scopes []string
+		want   error
+	}{
+		{"same scopes", manageOnly, []string{models.ScopeAPIKeysManage}, nil},
+		{"fewer scopes", listedScopes, []string{models.ScopeTodosRead}, nil},
+		{"all scopes caller", allScopes, []string{models.ScopeTodosWrite}, nil},
+		{"scope not held", manageOnly, []string{models.ScopeTodosWrite, models.ScopeAPIKeysManage}, ErrAPIKeyNotManageable},
+		// Keys without scopes grant every scope, even ones added later
+		{"unscoped key", listedScopes, nil, ErrAPIKeyNotManageable},
+		{"unscoped key by all scopes caller", allScopes, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, repo := newTestAPIKeyService()
+			ctx := context.Background()
+			key := models.APIKey{ID: "k1", OwnerID: "alice", Name: "k", KeyHash: "hash", Scopes: tt.scopes, CreatedAt: time.Now()}
+			if err := repo.Create(ctx, &key); err != nil {
+				t.Fatal(err)
+			}
+
+			rotated, err := service.RotateAPIKey(ctx, tt.caller, key.ID)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("RotateAPIKey() error = %v, want %v", err, tt.want)
+			}
+			if err != nil && rotated != nil {
+				t.Errorf("RotateAPIKey() returned the secret of %+v", rotated.APIKey)
+			}
+			if _, err := service.RevokeAPIKey(ctx, tt.caller, key.ID); !errors.Is(err, tt.want) {
+				t.Fatalf("RevokeAPIKey() error = %v, want %v", err, tt.want)
+			}
+
+			stored, err := repo.GetByID(ctx, "alice", key.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != nil && (stored.KeyHash != "hash" || stored.Revoked()) {
+				t.Errorf("key changed by a refused call: %+v", stored)
+			}
+		})
+	}
+}
+
+func TestRotateAPIKey(t *testing.T) {
+	service, _ := newTestAPIKeyService()
+	ctx := context.Background()
+	key := createTestKey(t, service, models.ScopeTodosRead)
+
+	rotated, err := service.RotateAPIKey(ctx, allScopes, key.ID)
+	if err != nil {
+		t.Fatalf("RotateAPIKey() error = %v", err)
+	}
+	if rotated.Key == key.Key || rotated.RotatedAt == nil || rotated.Name != key.Name {
+		t.Errorf("rotated key = %+v", rotated)
+	}
+	if _, err := service.AuthenticateAPIKey(ctx, key.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("AuthenticateAPIKey(old secret) error = %v, want ErrInvalidAPIKey", err)
+	}
+	if _, err := service.AuthenticateAPIKey(ctx, rotated.Key); err != nil {
+		t.Errorf("AuthenticateAPIKey(new secret) error = %v", err)
+	}
+
+	// Keys of other users do not exist for the caller
+	if _, err := service.RotateAPIKey(ctx, &auth.User{ID: "bob"}, key.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("RotateAPIKey(other owner) error = %v, want ErrAPIKeyNotFound", err)
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	service, _ := newTestAPIKeyService()
+	ctx := context.Background()
+	key := createTestKey(t, service, models.ScopeTodosRead)
+
+	revoked, err := service.RevokeAPIKey(ctx, allScopes, key.ID)
+	if err != nil {
+		t.Fatalf("RevokeAPIKey() error = %v", err)
+	}
+	if revoked.RevokedAt == nil {
+		t.Fatal("RevokedAt is not set")
+	}
+	if _, err := service.AuthenticateAPIKey(ctx, key.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("AuthenticateAPIKey(revoked) error = %v, want ErrInvalidAPIKey", err)
+	}
+
+	// Revoking again keeps the first revocation time
+	again, err := service.RevokeAPIKey(ctx, allScopes, key.ID)
+	if err != nil {
+		t.Fatalf("RevokeAPIKey(revoked) error = %v", err)
+	}
+	if !again.RevokedAt.Equal(*revoked.RevokedAt) {
+		t.Errorf("RevokedAt = %v, want %v", again.RevokedAt, revoked.RevokedAt)
+	}
+	if _, err := service.RotateAPIKey(ctx, allScopes, key.ID); !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Errorf("RotateAPIKey(revoked) error = %v, want ErrAPIKeyRevoked", err)
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	service, repo := newTestAPIKeyService()
+	ctx := context.Background()
+	active := createTestKey(t, service, models.ScopeTodosRead)
+	revoked := createTestKey(t, service, models.ScopeTodosRead)
+	if _, err := service.RevokeAPIKey(ctx, allScopes, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		want   error
+	}{
+		{"active", active.Key, nil},
+		{"revoked", revoked.Key, ErrInvalidAPIKey},
+		{"unknown", APIKeyPrefix + "unknown", ErrInvalidAPIKey},
+		{"without prefix", strings.TrimPrefix(active.Key, APIKeyPrefix), ErrInvalidAPIKey},
+		{"empty", "", ErrInvalidAPIKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := service.AuthenticateAPIKey(ctx, tt.secret)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("AuthenticateAPIKey() error = %v, want %v", err, tt.want)
+			}
+			if err == nil && (key.ID != active.ID || key.OwnerID != "alice" || !slices.Equal(key.Scopes, active.Scopes)) {
+				t.Errorf("AuthenticateAPIKey() = %+v", key)
+			}
+		})
+	}
+
+	// The first use is recorded, later uses within the interval are not
+	stored, err := repo.GetByID(ctx, "alice", active.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.LastUsedAt == nil {
+		t.Fatal("LastUsedAt is not set")
+	}
+	first := *stored.LastUsedAt
+	if _, err := service.AuthenticateAPIKey(ctx, active.Key); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := repo.GetByID(ctx, "alice", active.ID); !stored.LastUsedAt.Equal(first) {
+		t.Errorf("LastUsedAt = %v, want %v", stored.LastUsedAt, first)
+	}
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"echo-todo/internal/repository"
+	"echo-todo/internal/search"
+	"echo-todo/pkg/models"
+)
+
+const (
+	// titleBoost ranks title matches above description matches
+	titleBoost = 2.0
+	// descriptionSnippetLength is the maximum length of description highlights
+	descriptionSnippetLength = 160
+	// searchIndexMaxOwners caps the number of owners with an index in
+	// memory. Above it, the index of the least recently searching owner is
+	// dropped and loaded again on their next search.
+	searchIndexMaxOwners = 1000
+)
+
+// todoSearchIndex keeps an in-process full-text index of the todos of each
+// owner. An owner's index is filled from the repository on their first
+// search and kept in sync by the TodoService write methods afterwards.
+//
+// The index is per process: writes made by other server instances or Lambda
+// invocations are not seen, so results go stale when several of them serve
+// the same owner until the index is evicted or the process restarts.
+type todoSearchIndex struct {
+	// mu only guards the owners, each owner has its own lock so loading one
+	// owner does not block the others
+	mu        sync.Mutex
+	maxOwners int
+	owners    map[string]*list.Element
+	// recent orders the owners by their last search, most recent first
+	recent *list.List
+}
+
+type ownerSearchIndex struct {
+	ownerID string
+	// loaded is closed once the todos of the owner are indexed, err is set
+	// if that failed
+	loaded chan struct{}
+	err    error
+
+	mu    sync.Mutex
+	index *search.Index
+	todos map[string]models.Todo
+	// removed holds the todos deleted while the index is loading, so a page
+	// read before the delete does not add them back. It is nil once loaded.
+	removed map[string]bool
+}
+
+func newTodoSearchIndex() *todoSearchIndex {
+	return &todoSearchIndex{
+		maxOwners: searchIndexMaxOwners,
+		owners:    make(map[string]*list.Element),
+		recent:    list.New(),
+	}
+}
+
+// load returns the index of ownerID, indexing their todos if this is the
+// first search. Concurrent searches wait for the same load. Writes while
+// loading are applied to the index as it fills.
+func (si *todoSearchIndex) load(ctx context.Context, todoRepo repository.TodoRepository, ownerID string, logger *slog.Logger) (*ownerSearchIndex, error) {
+	si.mu.Lock()
+	var owner *ownerSearchIndex
+	elem, loading := si.owners[ownerID]
+	if loading {
+		owner = elem.Value.(*ownerSearchIndex)
+		si.recent.MoveToFront(elem)
+	} else {
+		owner = &ownerSearchIndex{
+			ownerID: ownerID,
+			loaded:  make(chan struct{}),
+			index:   search.NewIndex(),
+			todos:   make(map[string]models.Todo),
+			removed: make(map[string]bool),
+		}
+		si.owners[ownerID] = si.recent.PushFront(owner)
+		si.evict()
+	}
+	si.mu.Unlock()
+
+	if loading {
+		select {
+		case <-owner.loaded:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if owner.err != nil {
+			return nil, owner.err
+		}
+		return owner, nil
+	}
+
+	err := owner.fill(ctx, todoRepo, ownerID)
+	if err != nil {
+		// Drop the partial index so the next search loads it again
+		si.mu.Lock()
+		if elem, ok := si.owners[ownerID]; ok && elem.Value == owner {
+			si.drop(elem)
+		}
+		si.mu.Unlock()
+		owner.err = err
+	}
+	close(owner.loaded)
+	if err != nil {
+		return nil, err
+	}
+	owner.mu.Lock()
+	count := len(owner.todos)
+	owner.mu.Unlock()
+	logger.DebugContext(ctx, "loaded search index", "todos", count)
+	return owner, nil
+}
+
+// fill indexes every todo of ownerID, holding the owner's lock only while
+// adding each page
+func (o *ownerSearchIndex) fill(ctx context.Context, todoRepo repository.TodoRepository, ownerID string) error {
+	opts := models.TodoListOptions{Limit: models.MaxTodoListLimit}
+	for {
+		page, err := todoRepo.GetAll(ctx, ownerID, opts)
+		if err != nil {
+			return err
+		}
+		o.mu.Lock()
+		for i := range page.Todos {
+			if !o.removed[page.Todos[i].ID] {
+				o.put(page.Todos[i])
+			}
+		}
+		o.mu.Unlock()
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+
+	o.mu.Lock()
+	o.removed = nil
+	o.mu.Unlock()
+	return nil
+}
+
+// evict drops the least recently searched owners while there are more than
+// maxOwners. Searches holding a dropped index finish on it.
+func (si *todoSearchIndex) evict() {
+	for len(si.owners) > si.maxOwners {
+		si.drop(si.recent.Back())
+	}
+}
+
+func (si *todoSearchIndex) drop(elem *list.Element) {
+	si.recent.Remove(elem)
+	delete(si.owners, elem.Value.(*ownerSearchIndex).ownerID)
+}
+
+// owner returns the index of ownerID, nil if no search loaded it yet or it
+// was evicted
+func (si *todoSearchIndex) owner(ownerID string) *ownerSearchIndex {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	if elem, ok := si.owners[ownerID]; ok {
+		return elem.Value.(*ownerSearchIndex)
+	}
+	return nil
+}
+
+// add indexes a created or updated todo
+func (si *todoSearchIndex) add(todo *models.Todo) {
+	// Not loaded yet, the todo will be picked up by load
+	owner := si.owner(todo.OwnerID)
+	if owner == nil {
+		return
+	}
+
+	owner.mu.Lock()
+	defer owner.mu.Unlock()
+	owner.put(*todo)
+}
+
+// remove drops a deleted todo from the index
+func (si *todoSearchIndex) remove(ownerID, id string) {
+	owner := si.owner(ownerID)
+	if owner == nil {
+		return
+	}
+
+	owner.mu.Lock()
+	defer owner.mu.Unlock()
+	owner.index.Remove(id)
+	delete(owner.todos, id)
+	if owner.removed != nil {
+		owner.removed[id] = true
+	}
+}
+
+func (o *ownerSearchIndex) search(query string, limit int) []models.TodoSearchResult {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	hits := o.index.Search(query, limit)
+	results := make([]models.TodoSearchResult, 0, len(hits))
+	for _, hit := range hits {
+		todo := o.todos[hit.ID]
+		results = append(results, models.TodoSearchResult{
+			Todo:  todo,
+			Score: hit.Score,
+			Highlights: models.TodoHighlights{
+				Title:       search.Highlight(todo.Title, query, 0),
+				Description: search.Highlight(todo.Description, query, descriptionSnippetLength),
+			},
+		})
+	}
+
+	return results
+}
+
+// put indexes todo unless a newer version of it is already indexed, which
+// happens when a page read while loading is older than a concurrent update
+func (o *ownerSearchIndex) put(todo models.Todo) {
+	if indexed, ok := o.todos[todo.ID]; ok && indexed.Version > todo.Version {
+		return
+	}
+	o.index.Add(todo.ID,
+		search.Field{Text: todo.Title, Boost: titleBoost},
+		search.Field{Text: todo.Description},
+	)
+	o.todos[todo.ID] = todo
+}
+
+func (s *todoService) SearchTodos(ctx context.Context, ownerID, query string, limit int) ([]models.TodoSearchResult, error) {
+	// Queries without any searchable word match nothing
+	if len(search.Terms(strings.TrimSpace(query))) == 0 {
+		return nil, ErrInvalidSearchQuery
+	}
+
+	// Clamp result count to the allowed range
+	if limit <= 0 {
+		limit = models.DefaultTodoListLimit
+	}
+	if limit > models.MaxTodoListLimit {
+		limit = models.MaxTodoListLimit
+	}
+
+	owner, err := s.searchIndex.load(ctx, s.todoRepo, ownerID, s.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return owner.search(query, limit), nil
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"echo-todo/internal/repository"
+	"echo-todo/pkg/models"
+)
+
+func searchTitles(t *testing.T, service TodoService, ownerID, query string) []string {
+	t.Helper()
+	results, err := service.SearchTodos(context.Background(), ownerID, query, 0)
+	if err != nil {
+		t.Fatalf("SearchTodos(%s, %q) error = %v", ownerID, query, err)
+	}
+	titles := []string{}
+	for _, result := range results {
+		titles = append(titles, result.Todo.Title)
+	}
+	return titles
+}
+
+func TestSearchIndexEviction(t *testing.T) {
+	repo := repository.NewMemoryTodoRepository()
+	service := NewTodoService(repo, testLogger()).(*todoService)
+	service.searchIndex.maxOwners = 2
+	ctx := context.Background()
+
+	for _, owner := range []string{"alice", "bob", "carol"} {
+		if _, err := service.CreateTodo(ctx, owner, &models.CreateTodoRequest{Title: "milk for " + owner}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	searchTitles(t, service, "alice", "milk")
+	searchTitles(t, service, "bob", "milk")
+	// Searching again makes alice the most recent owner
+	searchTitles(t, service, "alice", "milk")
+	searchTitles(t, service, "carol", "milk")
+
+	if service.searchIndex.owner("bob") != nil {
+		t.Error("least recently searching owner was kept")
+	}
+	if service.searchIndex.owner("alice") == nil || service.searchIndex.owner("carol") == nil {
+		t.Error("recently searching owner was evicted")
+	}
+	if n := len(service.searchIndex.owners); n != 2 || service.searchIndex.recent.Len() != 2 {
+		t.Errorf("%d owners indexed, want 2", n)
+	}
+
+	// A todo written past the service, e.g. by another instance, is found
+	// once the evicted index is loaded again
+	now := time.Now()
+	if err := repo.Create(ctx, &models.Todo{ID: "elsewhere", OwnerID: "bob", Title: "milk from elsewhere", CreatedAt: now, UpdatedAt: now, Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchTitles(t, service, "bob", "elsewhere"); len(got) != 1 {
+		t.Errorf("search after reload = %v", got)
+	}
+
+	// Writes keep the loaded indexes up to date
+	if _, err := service.CreateTodo(ctx, "bob", &models.CreateTodoRequest{Title: "more milk"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchTitles(t, service, "bob", "milk"); len(got) != 3 {
+		t.Errorf("search after create = %v", got)
+	}
+}
@@ -3,139 +3,205 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
 
+	"echo-todo/internal/apperror"
 	"echo-todo/internal/repository"
 	"echo-todo/pkg/models"
 )
 
 var (
-	ErrTodoNotFound = errors.New("todo not found")
+	ErrTodoNotFound       = repository.ErrTodoNotFound
+	ErrVersionConflict    = repository.ErrVersionConflict
+	ErrInvalidCursor      = repository.ErrInvalidCursor
+	ErrInvalidListOptions = apperror.Validation("invalid list options")
+	ErrInvalidSearchQuery = apperror.Validation("search query must contain at least one word")
 )
 
+// TodoPatch changes the writable fields of a todo, given as the request
+// that would replace them. It returns an error if it cannot be applied to
+// their current values.
+type TodoPatch func(todo *models.UpdateTodoRequest) error
+
 type TodoService interface {
-	CreateTodo(ctx context.Context, req *models.CreateTodoRequest) (*models.Todo, error)
-	GetTodoByID(ctx context.Context, id string) (*models.Todo, error)
-	GetAllTodos(ctx context.Context) ([]models.Todo, error)
-	UpdateTodo(ctx context.Context, id string, req *models.UpdateTodoRequest) (*models.Todo, error)
-	DeleteTodo(ctx context.Context, id string) error
+	CreateTodo(ctx context.Context, ownerID string, req *models.CreateTodoRequest) (*models.Todo, error)
+	// GetTodoByID, UpdateTodo, PatchTodo and DeleteTodo return
+	// ErrTodoNotFound if the owner has no todo with id
+	GetTodoByID(ctx context.Context, ownerID, id string) (*models.Todo, error)
+	GetAllTodos(ctx context.Context, ownerID string, opts models.TodoListOptions) (*models.TodoPage, error)
+	// UpdateTodo replaces the writable fields of a todo, PatchTodo applies
+	// patch to their current values and writes them only if it succeeds.
+	// Both and DeleteTodo return ErrVersionConflict when version is not zero
+	// and the todo is at a different version.
+	UpdateTodo(ctx context.Context, ownerID, id string, req *models.UpdateTodoRequest, version int) (*models.Todo, error)
+	PatchTodo(ctx context.Context, ownerID, id string, patch TodoPatch, version int) (*models.Todo, error)
+	DeleteTodo(ctx context.Context, ownerID, id string, version int) error
+	SearchTodos(ctx context.Context, ownerID, query string, limit int) ([]models.TodoSearchResult, error)
 }
 
 type todoService struct {
-	todoRepo repository.TodoRepository
+	todoRepo    repository.TodoRepository
+	searchIndex *todoSearchIndex
+	logger      *slog.Logger
 }
 
-func NewTodoService(todoRepo repository.TodoRepository) TodoService {
+func NewTodoService(todoRepo repository.TodoRepository, logger *slog.Logger) TodoService {
 	return &todoService{
-		todoRepo: todoRepo,
+		todoRepo:    todoRepo,
+		searchIndex: newTodoSearchIndex(),
+		logger:      logger,
 	}
 }
 
-func (s *todoService) CreateTodo(ctx context.Context, req *models.CreateTodoRequest) (*models.Todo, error) {
+func (s *todoService) CreateTodo(ctx context.Context, ownerID string, req *models.CreateTodoRequest) (*models.Todo, error) {
 	// Generate unique ID
 	id := generateID()
-	
+
 	// Create todo entity with timestamps
 	todo := &models.Todo{
 		ID:          id,
+		OwnerID:     ownerID,
 		Title:       req.Title,
 		Description: req.Description,
 		Completed:   false,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
+		Version:     1,
 	}
-	
+
 	// Save to repository
 	err := s.todoRepo.Create(ctx, todo)
 	if err != nil {
 		return nil, err
 	}
-	
+	s.searchIndex.add(todo)
+	s.logger.DebugContext(ctx, "created todo", "todo_id", todo.ID)
+
 	return todo, nil
 }
 
-func (s *todoService) GetTodoByID(ctx context.Context, id string) (*models.Todo, error) {
-	// Get todo from repository
-	todo, err := s.todoRepo.GetByID(ctx, id)
-	if err != nil {
-		return nil, err
+func (s *todoService) GetTodoByID(ctx context.Context, ownerID, id string) (*models.Todo, error) {
+	// Get todo from repository, ErrTodoNotFound if it does not exist
+	return s.todoRepo.GetByID(ctx, ownerID, id)
+}
+
+func (s *todoService) GetAllTodos(ctx context.Context, ownerID string, opts models.TodoListOptions) (*models.TodoPage, error) {
+	// Clamp page size to the allowed range
+	if opts.Limit <= 0 {
+		opts.Limit = models.DefaultTodoListLimit
 	}
-	
-	// Return nil if todo not found (repository returns nil, nil for not found)
-	if todo == nil {
-		return nil, nil
+	if opts.Limit > models.MaxTodoListLimit {
+		opts.Limit = models.MaxTodoListLimit
+	}
+
+	// Default to oldest first
+	switch opts.SortBy {
+	case "":
+		opts.SortBy = models.TodoSortCreatedAt
+	case models.TodoSortCreatedAt, models.TodoSortUpdatedAt, models.TodoSortTitle:
+	default:
+		return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidListOptions, opts.SortBy)
 	}
-	
-	return todo, nil
-}
 
-func (s *todoService) GetAllTodos(ctx context.Context) ([]models.Todo, error) {
-	todos,err := s.todoRepo.GetAll(ctx)
+	if opts.CreatedAfter != nil && opts.CreatedBefore != nil && !opts.CreatedAfter.Before(*opts.CreatedBefore) {
+		return nil, fmt.Errorf("%w: created_after must be before created_before", ErrInvalidListOptions)
+	}
+
+	page, err := s.todoRepo.GetAll(ctx, ownerID, opts)
 	if err != nil {
 		return nil, err
 	}
-	return todos, nil
+	return page, nil
 }
 
-func (s *todoService) UpdateTodo(ctx context.Context, id string, req *models.UpdateTodoRequest) (*models.Todo, error) {
+func (s *todoService) UpdateTodo(ctx context.Context, ownerID, id string, req *models.UpdateTodoRequest, version int) (*models.Todo, error) {
+	return s.PatchTodo(ctx, ownerID, id, func(todo *models.UpdateTodoRequest) error {
+		*todo = *req
+		return nil
+	}, version)
+}
+
+func (s *todoService) PatchTodo(ctx context.Context, ownerID, id string, patch TodoPatch, version int) (*models.Todo, error) {
 	// Get existing todo
-	existingTodo, err := s.todoRepo.GetByID(ctx, id)
+	existingTodo, err := s.todoRepo.GetByID(ctx, ownerID, id)
 	if err != nil {
 		return nil, err
 	}
-	
-	// Check if todo exists
-	if existingTodo == nil {
-		return nil, nil
+	if version != 0 && existingTodo.Version != version {
+		return nil, ErrVersionConflict
+	}
+
+	// Apply the patch to the current fields
+	patched := models.UpdateTodoRequest{
+		Title:       existingTodo.Title,
+		Description: existingTodo.Description,
+		Completed:   existingTodo.Completed,
+	}
+	if err := patch(&patched); err != nil {
+		return nil, err
+	}
+
+	// Update the fields that changed, the version is kept if none did
+	var fields []repository.TodoField
+	if patched.Title != existingTodo.Title {
+		existingTodo.Title = patched.Title
+		fields = append(fields, repository.TodoFieldTitle)
 	}
-	
-	// Update fields if provided
-	if req.Title != nil {
-		existingTodo.Title = *req.Title
+	if patched.Description != existingTodo.Description {
+		existingTodo.Description = patched.Description
+		fields = append(fields, repository.TodoFieldDescription)
 	}
-	if req.Description != nil {
-		existingTodo.Description = *req.Description
+	if patched.Completed != existingTodo.Completed {
+		existingTodo.Completed = patched.Completed
+		fields = append(fields, repository.TodoFieldCompleted)
 	}
-	if req.Completed != nil {
-		existingTodo.Completed = *req.Completed
+	if len(fields) == 0 {
+		return existingTodo, nil
 	}
-	
+
 	// Update timestamp
 	existingTodo.UpdatedAt = time.Now()
-	
-	// Save updated todo
-	err = s.todoRepo.Update(ctx, existingTodo)
+
+	// Save updated todo, the todo may have been deleted since it was read
+	err = s.todoRepo.Update(ctx, existingTodo, fields)
+	if errors.Is(err, ErrTodoNotFound) {
+		s.searchIndex.remove(ownerID, id)
+	}
 	if err != nil {
 		return nil, err
 	}
-	
+	s.searchIndex.add(existingTodo)
+	s.logger.DebugContext(ctx, "updated todo", "todo_id", id)
+
 	return existingTodo, nil
 }
 
-func (s *todoService) DeleteTodo(ctx context.Context, id string) error {
-	// Check if todo exists before deletion
-	existingTodo, err := s.todoRepo.GetByID(ctx, id)
+func (s *todoService) DeleteTodo(ctx context.Context, ownerID, id string, version int) error {
+	// Check if todo exists before deletion, ErrTodoNotFound otherwise
+	existingTodo, err := s.todoRepo.GetByID(ctx, ownerID, id)
 	if err != nil {
 		return err
 	}
-	
-	// Return specific error if todo not found
-	if existingTodo == nil {
-		return ErrTodoNotFound
+	if version != 0 && existingTodo.Version != version {
+		return ErrVersionConflict
 	}
-	
+
 	// Delete todo from repository
-	err = s.todoRepo.Delete(ctx, id)
+	err = s.todoRepo.Delete(ctx, ownerID, id, version)
 	if err != nil {
 		return err
 	}
-	
+	s.searchIndex.remove(ownerID, id)
+	s.logger.DebugContext(ctx, "deleted todo", "todo_id", id)
+
 	return nil
 }
 
 func generateID() string {
 	// Generate UUID v4 for unique ID
 	return uuid.New().String()
-}
\ No newline at end of file
+}
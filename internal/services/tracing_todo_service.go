@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"echo-todo/internal/apperror"
+	"echo-todo/pkg/models"
+)
+
+// tracingTodoService decorates a TodoService with a span per method call
+type tracingTodoService struct {
+	next   TodoService
+	tracer trace.Tracer
+}
+
+// NewTracingTodoService wraps next so every call is traced with the global
+// tracer provider
+func NewTracingTodoService(next TodoService) TodoService {
+	return &tracingTodoService{
+		next:   next,
+		tracer: otel.Tracer("echo-todo/internal/services"),
+	}
+}
+
+func (s *tracingTodoService) start(ctx context.Context, method, ownerID string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "TodoService."+method, trace.WithAttributes(
+		append(attrs, attribute.String("todo.owner_id", ownerID))...,
+	))
+}
+
+// endSpan records err on span and ends it. Only unexpected errors mark the
+// span as failed, not e.g. a missing todo.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		switch apperror.KindOf(err) {
+		case apperror.KindInternal, apperror.KindUnavailable:
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
+
+func (s *tracingTodoService) CreateTodo(ctx context.Context, ownerID string, req *models.CreateTodoRequest) (*models.Todo, error) {
+	ctx, span := s.start(ctx, "CreateTodo", ownerID)
+	todo, err := s.next.CreateTodo(ctx, ownerID, req)
+	if todo != nil {
+		span.SetAttributes(attribute.String("todo.id", todo.ID))
+	}
+	endSpan(span, err)
+	return todo, err
+}
+
+func (s *tracingTodoService) GetTodoByID(ctx context.Context, ownerID, id string) (*models.Todo, error) {
+	ctx, span := s.start(ctx, "GetTodoByID", ownerID, attribute.String("todo.id", id))
+	todo, err := s.next.GetTodoByID(ctx, ownerID, id)
+	span.SetAttributes(attribute.Bool("todo.found", todo != nil))
+	endSpan(span, err)
+	return todo, err
+}
+
+func (s *tracingTodoService) GetAllTodos(ctx context.Context, ownerID string, opts models.TodoListOptions) (*models.TodoPage, error) {
+	ctx, span := s.start(ctx, "GetAllTodos", ownerID,
+		attribute.Int("todo.list.limit", opts.Limit),
+		attribute.String("todo.list.sort_by", string(opts.SortBy)),
+	)
+	page, err := s.next.GetAllTodos(ctx, ownerID, opts)
+	if page != nil {
+		span.SetAttributes(attribute.Int("todo.list.count", len(page.Todos)))
+	}
+	endSpan(span, err)
+	return page, err
+}
+
+func (s *tracingTodoService) UpdateTodo(ctx context.Context, ownerID, id string, req *models.UpdateTodoRequest, version int) (*models.Todo, error) {
+	ctx, span := s.start(ctx, "UpdateTodo", ownerID, attribute.String("todo.id", id), attribute.Int("todo.version", version))
+	todo, err := s.next.UpdateTodo(ctx, ownerID, id, req, version)
+	endSpan(span, err)
+	return todo, err
+}
+
+func (s *tracingTodoService) PatchTodo(ctx context.Context, ownerID, id string, patch TodoPatch, version int) (*models.Todo, error) {
+	ctx, span := s.start(ctx, "PatchTodo", ownerID, attribute.String("todo.id", id), attribute.Int("todo.version", version))
+	todo, err := s.next.PatchTodo(ctx, ownerID, id, patch, version)
+	endSpan(span, err)
+	return todo, err
+}
+
+func (s *tracingTodoService) DeleteTodo(ctx context.Context, ownerID, id string, version int) error {
+	ctx, span := s.start(ctx, "DeleteTodo", ownerID, attribute.String("todo.id", id), attribute.Int("todo.version", version))
+	err := s.next.DeleteTodo(ctx, ownerID, id, version)
+	endSpan(span, err)
+	return err
+}
+
+func (s *tracingTodoService) SearchTodos(ctx context.Context, ownerID, query string, limit int) ([]models.TodoSearchResult, error) {
+	ctx, span := s.start(ctx, "SearchTodos", ownerID, attribute.Int("todo.search.limit", limit))
+	results, err := s.next.SearchTodos(ctx, ownerID, query, limit)
+	span.SetAttributes(attribute.Int("todo.search.count", len(results)))
+	endSpan(span, err)
+	return results, err
+}
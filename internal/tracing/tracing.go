@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// ServiceName identifies the application in traces
+const ServiceName = "echo-todo"
+
+// Supported span exporters
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Options configures the tracer provider installed by Setup
+type Options struct {
+	// Exporter is one of ExporterNone, ExporterStdout or ExporterOTLP
+	Exporter string
+	// OTLPEndpoint is the OTLP/HTTP collector URL, e.g.
+	// http://localhost:4318. When empty the OTEL_EXPORTER_OTLP_* environment
+	// variables apply.
+	OTLPEndpoint string
+	// SampleRatio is the fraction of new traces recorded. Requests carrying
+	// a traceparent header follow the sampling decision of the caller.
+	SampleRatio float64
+	Environment string
+}
+
+// Setup installs the global W3C trace context propagator and, unless the
+// exporter is ExporterNone, a tracer provider exporting spans. Spans of the
+// stdout exporter are written to w. The returned function flushes pending
+// spans and must be called before the process exits.
+func Setup(ctx context.Context, opts Options, w io.Writer) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporterOpt sdktrace.TracerProviderOption
+	switch opts.Exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			return nil, err
+		}
+		// Export synchronously so spans show up as soon as they end
+		exporterOpt = sdktrace.WithSyncer(exporter)
+	case ExporterOTLP:
+		var clientOpts []otlptracehttp.Option
+		if opts.OTLPEndpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpointURL(opts.OTLPEndpoint))
+		}
+		exporter, err := otlptracehttp.New(ctx, clientOpts...)
+		if err != nil {
+			return nil, err
+		}
+		exporterOpt = sdktrace.WithBatcher(exporter)
+	default:
+		return nil, fmt.Errorf("invalid tracing exporter %q", opts.Exporter)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(
+			semconv.ServiceName(ServiceName),
+			semconv.DeploymentEnvironment(opts.Environment),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		exporterOpt,
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+)
+
+// Scopes an API key can be granted
+const (
+	ScopeTodosRead     = "todos:read"
+	ScopeTodosWrite    = "todos:write"
+	ScopeAPIKeysManage = "api_keys:manage"
+)
+
+// APIKeyScopes lists every scope an API key can be granted
+var APIKeyScopes = []string{ScopeTodosRead, ScopeTodosWrite, ScopeAPIKeysManage}
+
+// APIKey is a long-lived credential for scripts and bots. Only the SHA-256
+// hash of the secret key is stored.
+type APIKey struct {
+	ID      string `json:"id" dynamodbav:"id"`
+	OwnerID string `json:"owner_id" dynamodbav:"owner_id"`
+	Name    string `json:"name" dynamodbav:"name"`
+	// Prefix is the start of the key, shown to tell keys apart
+	Prefix  string   `json:"prefix" dynamodbav:"prefix"`
+	KeyHash string   `json:"-" dynamodbav:"key_hash"`
+	Scopes  []string `json:"scopes" dynamodbav:"scopes,stringset"`
+
+	CreatedAt  time.Time  `json:"created_at" dynamodbav:"created_at"`
+	RotatedAt  *time.Time `json:"rotated_at,omitempty" dynamodbav:"rotated_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" dynamodbav:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" dynamodbav:"revoked_at,omitempty"`
+}
+
+// Revoked reports whether the key can no longer be used
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required"`
+}
+
+// CreatedAPIKey is returned when a key is created or rotated. Key is the
+// secret and is never shown again.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -1,26 +1,128 @@
 package models
 
 import (
+	"strings"
 	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 type Todo struct {
 	ID          string    `json:"id" dynamodbav:"id"`
+	OwnerID     string    `json:"owner_id" dynamodbav:"owner_id"`
 	Title       string    `json:"title" dynamodbav:"title"`
 	Description string    `json:"description" dynamodbav:"description"`
 	Completed   bool      `json:"completed" dynamodbav:"completed"`
 	CreatedAt   time.Time `json:"created_at" dynamodbav:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" dynamodbav:"updated_at"`
+	// Version starts at 1 and is incremented by every update. It is sent as
+	// the ETag of the todo.
+	Version int `json:"version" dynamodbav:"version"`
 }
 
+// CreateTodoRequest is the body creating a todo. The todo_title and
+// todo_description rules check the lengths configured at startup and reject
+// control characters, see utils.SetTodoTextLimits.
 type CreateTodoRequest struct {
-	Title       string `json:"title" validate:"required"`
-	Description string `json:"description"`
+	Title       string `json:"title" validate:"required,todo_title"`
+	Description string `json:"description" validate:"todo_description"`
+}
+
+// Normalize trims and normalizes the text fields, it is applied before
+// validation
+func (r *CreateTodoRequest) Normalize() {
+	r.Title = NormalizeTodoTitle(r.Title)
+	r.Description = NormalizeTodoDescription(r.Description)
 }
 
+// UpdateTodoRequest replaces every writable field of a todo, fields left
+// out are cleared. It is also the document PATCH requests are applied to.
 type UpdateTodoRequest struct {
-	Title       *string `json:"title,omitempty"`
-	Description *string `json:"description,omitempty"`
-	Completed   *bool   `json:"completed,omitempty"`
+	Title       string `json:"title" validate:"required,todo_title"`
+	Description string `json:"description" validate:"todo_description"`
+	Completed   bool   `json:"completed"`
+}
+
+// Normalize trims and normalizes the text fields, it is applied before
+// validation
+func (r *UpdateTodoRequest) Normalize() {
+	r.Title = NormalizeTodoTitle(r.Title)
+	r.Description = NormalizeTodoDescription(r.Description)
+}
+
+// NormalizeTodoTitle returns title in Unicode NFC with surrounding spaces
+// removed and inner runs of spaces collapsed to one. Only space separators
+// such as U+0020 and U+3000 count as spaces: line breaks, tabs and other
+// control characters are kept so validation rejects them.
+func NormalizeTodoTitle(title string) string {
+	isSpace := func(r rune) bool { return unicode.Is(unicode.Zs, r) }
+	return strings.Join(strings.FieldsFunc(norm.NFC.String(title), isSpace), " ")
+}
+
+// NormalizeTodoDescription returns description in Unicode NFC with line
+// breaks converted to \n and surrounding whitespace removed
+func NormalizeTodoDescription(description string) string {
+	description = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(norm.NFC.String(description))
+	return strings.TrimSpace(description)
 }
 
+// Page size limits for todo listings
+const (
+	DefaultTodoListLimit = 20
+	MaxTodoListLimit     = 100
+)
+
+// TodoSortField is a todo attribute listings can be ordered by
+type TodoSortField string
+
+const (
+	TodoSortCreatedAt TodoSortField = "created_at"
+	TodoSortUpdatedAt TodoSortField = "updated_at"
+	TodoSortTitle     TodoSortField = "title"
+)
+
+// TodoListOptions controls which todos are listed, in which order and
+// which page of them is returned
+type TodoListOptions struct {
+	// Limit is the maximum number of todos in the page
+	Limit int
+	// Cursor is the opaque NextCursor of the previous page, empty for the first page
+	Cursor string
+
+	// Completed keeps only todos with the given completion state when set
+	Completed *bool
+	// CreatedAfter and CreatedBefore are exclusive bounds on CreatedAt when set
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// UpdatedSince is an inclusive lower bound on UpdatedAt when set
+	UpdatedSince *time.Time
+
+	// SortBy is the attribute to order by, ties are broken by ID. DynamoDB
+	// indexes sort on the attribute alone, so the order of ties is undefined.
+	SortBy TodoSortField
+	// SortDesc reverses the order
+	SortDesc bool
+}
+
+// TodoPage is a single page of todos
+type TodoPage struct {
+	Todos []Todo
+	// NextCursor is empty when there are no more todos
+	NextCursor string
+}
+
+// TodoSearchResult is a todo matching a search query
+type TodoSearchResult struct {
+	Todo Todo `json:"todo"`
+	// Score ranks results, higher is more relevant
+	Score float64 `json:"score"`
+	// Highlights contains the matched text with matches wrapped in <mark> tags
+	Highlights TodoHighlights `json:"highlights"`
+}
+
+// TodoHighlights are HTML escaped snippets of a todo with matches marked
+type TodoHighlights struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultMaxRequestBodyBytes is the default size limit of JSON request bodies
+const DefaultMaxRequestBodyBytes = 1 << 20
+
+// maxRequestBodyBytes is the size limit of JSON request bodies
+var maxRequestBodyBytes int64 = DefaultMaxRequestBodyBytes
+
+// SetMaxRequestBodyBytes sets the size limit of JSON request bodies in
+// bytes. It must be called before requests are read.
+func SetMaxRequestBodyBytes(n int64) {
+	maxRequestBodyBytes = n
+}
+
+// JSONBinder binds requests like echo.DefaultBinder but decodes JSON bodies
+// strictly: bodies must be valid UTF-8, at most SetMaxRequestBodyBytes
+// bytes long and hold a single JSON value, and
+// fields with the wrong type are reported as ValidationErrors in the language
+// of the Accept-Language header. Other content types are bound by
+// echo.DefaultBinder.
+type JSONBinder struct {
+	echo.DefaultBinder
+	// DisallowUnknownFields rejects JSON objects with fields the target has
+	// no field for
+	DisallowUnknownFields bool
+}
+
+func (b *JSONBinder) Bind(i interface{}, c echo.Context) error {
+	req := c.Request()
+	if req.ContentLength == 0 || !strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		return b.DefaultBinder.Bind(i, c)
+	}
+
+	if err := b.BindPathParams(c, i); err != nil {
+		return err
+	}
+	// Like echo.DefaultBinder, query parameters are only bound for methods
+	// without a body
+	switch req.Method {
+	case http.MethodGet, http.MethodDelete, http.MethodHead:
+		if err := b.BindQueryParams(c, i); err != nil {
+			return err
+		}
+	}
+	return b.bindJSON(i, c)
+}
+
+// bindJSON decodes the JSON body of the request into i
+func (b *JSONBinder) bindJSON(i interface{}, c echo.Context) error {
+	body, err := ReadJSONBody(c)
+	if err != nil {
+		return err
+	}
+	return DecodeJSON(c, body, i, b.DisallowUnknownFields)
+}
+
+// ReadJSONBody reads the body of the request, which must be valid UTF-8.
+// Bodies over the size limit are answered with 413.
+func ReadJSONBody(c echo.Context) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, maxRequestBodyBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		message, _ := requestTranslator(c).T(bodyTooLargeKey, strconv.FormatInt(tooLarge.Limit, 10))
+		return nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, message)
+	}
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Failed to read request body").SetInternal(err)
+	}
+	if !utf8.Valid(body) {
+		message, _ := requestTranslator(c).T(invalidUTF8Key)
+		return nil, echo.NewHTTPError(http.StatusBadRequest, message)
+	}
+	return body, nil
+}
+
+// DecodeJSON decodes data, which must hold a single JSON value, into i.
+// Fields with the wrong type, and unknown fields if disallowUnknownFields is
+// set, are reported as ValidationErrors in the language of the request.
+func DecodeJSON(c echo.Context, data []byte, i interface{}, disallowUnknownFields bool) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	err := dec.Decode(i)
+	if errors.Is(err, io.EOF) {
+		// An empty body binds nothing
+		return nil
+	}
+	if err == nil {
+		// Anything after the value, even a stray closing delimiter, is an error
+		if _, tokenErr := dec.Token(); !errors.Is(tokenErr, io.EOF) {
+			err = errors.New("json: data after the top-level value")
+		}
+	}
+	if err == nil {
+		return nil
+	}
+
+	trans := requestTranslator(c)
+	var fieldErr *FieldError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		param := jsonType(typeErr.Type)
+		message, _ := trans.T(fieldTypeKey, typeErr.Field, param)
+		fieldErr = &FieldError{Field: typeErr.Field, Rule: "type", Param: param, Message: message}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// The decoder has no error type for unknown fields
+		field, unquoteErr := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		if unquoteErr == nil {
+			message, _ := trans.T(unknownFieldKey, field)
+			fieldErr = &FieldError{Field: field, Rule: "unknown_field", Message: message}
+		}
+	}
+	if fieldErr == nil {
+		message, _ := trans.T(malformedBodyKey)
+		return echo.NewHTTPError(http.StatusBadRequest, message).SetInternal(err)
+	}
+	fields := ValidationErrors{*fieldErr}
+	return echo.NewHTTPError(http.StatusBadRequest, fields.Error()).SetInternal(fields)
+}
+
+// jsonType returns the name of the JSON type a Go type is decoded from
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestReadJSONBodyLimit(t *testing.T) {
+	SetMaxRequestBodyBytes(16)
+	t.Cleanup(func() { SetMaxRequestBodyBytes(DefaultMaxRequestBodyBytes) })
+
+	tests := []struct {
+		name     string
+		body     string
+		language string
+		status   int
+		message  string
+	}{
+		{"at limit", `{"title":"abcd"}`, "en", 0, ""},
+		{"over limit", `{"title":"abcde"}`, "en", http.StatusRequestEntityTooLarge, "request body must not exceed 16 bytes"},
+		{"over limit ja", `{"title":"abcde"}`, "ja", http.StatusRequestEntityTooLarge, "リクエストボディは16バイト以下である必要があります"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set("Accept-Language", tt.language)
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+			body, err := ReadJSONBody(c)
+			if tt.status == 0 {
+				if err != nil || string(body) != tt.body {
+					t.Errorf("ReadJSONBody() = %q, %v", body, err)
+				}
+				return
+			}
+			var he *echo.HTTPError
+			if !errors.As(err, &he) || he.Code != tt.status || he.Message != tt.message {
+				t.Errorf("ReadJSONBody() error = %v, want %d %q", err, tt.status, tt.message)
+			}
+		})
+	}
+}
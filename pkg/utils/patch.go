@@ -0,0 +1,292 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Media types of the patch documents accepted by PATCH requests
+const (
+	MIMEApplicationMergePatchJSON = "application/merge-patch+json"
+	MIMEApplicationJSONPatchJSON  = "application/json-patch+json"
+)
+
+var (
+	// ErrInvalidPatch means the patch document is malformed
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrPatchConflict means the patch cannot be applied to the current
+	// document, e.g. a test operation failed or a path does not exist
+	ErrPatchConflict = errors.New("patch cannot be applied")
+)
+
+// ApplyMergePatch applies a JSON Merge Patch (RFC 7396) to the JSON document
+// doc: members of patch replace those of doc and null members remove them.
+func ApplyMergePatch(doc, patch []byte) ([]byte, error) {
+	var target, p interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	return json.Marshal(mergePatch(target, p))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+		} else {
+			t[key] = mergePatch(t[key], value)
+		}
+	}
+	return t
+}
+
+// IsJSONObject reports whether data is a JSON object
+func IsJSONObject(data []byte) bool {
+	var object map[string]json.RawMessage
+	return json.Unmarshal(data, &object) == nil && object != nil
+}
+
+// ApplyJSONPatch applies the operations of a JSON Patch (RFC 6902) to the
+// JSON document doc in order. The patch is atomic: if any operation fails,
+// including a test, an error is returned and doc is left as it was.
+func ApplyJSONPatch(doc, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	// Operations are decoded as maps to tell a null value from a missing one
+	var ops []map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: must be an array of operations", ErrInvalidPatch)
+	}
+
+	for i, op := range ops {
+		var err error
+		if target, err = applyOperation(target, op); err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+// applyOperation applies a single JSON Patch operation to doc
+func applyOperation(doc interface{}, op map[string]json.RawMessage) (interface{}, error) {
+	var name string
+	if err := json.Unmarshal(op["op"], &name); err != nil {
+		return nil, fmt.Errorf("%w: op must be a string", ErrInvalidPatch)
+	}
+	path, err := operationPointer(op, "path")
+	if err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case "add", "replace", "test":
+		raw, ok := op["value"]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s requires a value", ErrInvalidPatch, name)
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		switch name {
+		case "add":
+			return addValue(doc, path, value)
+		case "replace":
+			if len(path) == 0 {
+				return value, nil
+			}
+			if doc, err = removeValue(doc, path); err != nil {
+				return nil, err
+			}
+			return addValue(doc, path, value)
+		default:
+			current, err := getValue(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(current, value) {
+				return nil, fmt.Errorf("%w: test of %s failed", ErrPatchConflict, pointerString(path))
+			}
+			return doc, nil
+		}
+	case "remove":
+		return removeValue(doc, path)
+	case "move", "copy":
+		from, err := operationPointer(op, "from")
+		if err != nil {
+			return nil, err
+		}
+		value, err := getValue(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if name == "copy" {
+			// Copy through JSON so the two locations do not share containers
+			data, _ := json.Marshal(value)
+			_ = json.Unmarshal(data, &value)
+			return addValue(doc, path, value)
+		}
+		if len(from) < len(path) && reflect.DeepEqual(from, path[:len(from)]) {
+			return nil, fmt.Errorf("%w: cannot move %s into itself", ErrInvalidPatch, pointerString(from))
+		}
+		if doc, err = removeValue(doc, from); err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+	default:
+		return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, name)
+	}
+}
+
+// operationPointer returns the reference tokens of the JSON Pointer
+// (RFC 6901) in member key of op
+func operationPointer(op map[string]json.RawMessage, key string) ([]string, error) {
+	var pointer string
+	if err := json.Unmarshal(op[key], &pointer); err != nil {
+		return nil, fmt.Errorf("%w: %s must be a JSON Pointer string", ErrInvalidPatch, key)
+	}
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: %s %q must start with /", ErrInvalidPatch, key, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+func pointerString(tokens []string) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteString("/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(token))
+	}
+	return b.String()
+}
+
+// getValue returns the value at path in doc
+func getValue(doc interface{}, path []string) (interface{}, error) {
+	for i, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s does not exist", ErrPatchConflict, pointerString(path[:i+1]))
+			}
+			doc = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrPatchConflict, pointerString(path[:i+1]), err)
+			}
+			doc = node[index]
+		default:
+			return nil, fmt.Errorf("%w: %s does not exist", ErrPatchConflict, pointerString(path[:i+1]))
+		}
+	}
+	return doc, nil
+}
+
+// addValue adds value at path in doc, replacing an existing object member
+// or inserting into an array, and returns the updated document
+func addValue(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := getValue(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	token := path[len(path)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[token] = value
+		return doc, nil
+	case []interface{}:
+		index := len(node)
+		if token != "-" {
+			if index, err = arrayIndex(token, len(node)); err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrPatchConflict, pointerString(path), err)
+			}
+		}
+		node = append(node[:index], append([]interface{}{value}, node[index:]...)...)
+		return replaceValue(doc, path[:len(path)-1], node), nil
+	default:
+		return nil, fmt.Errorf("%w: %s does not exist", ErrPatchConflict, pointerString(path[:len(path)-1]))
+	}
+}
+
+// removeValue removes the value at path from doc and returns the updated
+// document
+func removeValue(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: cannot remove the whole document", ErrInvalidPatch)
+	}
+	if _, err := getValue(doc, path); err != nil {
+		return nil, err
+	}
+
+	parent, _ := getValue(doc, path[:len(path)-1])
+	token := path[len(path)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		delete(node, token)
+		return doc, nil
+	default:
+		items := node.([]interface{})
+		index, _ := arrayIndex(token, len(items)-1)
+		items = append(items[:index:index], items[index+1:]...)
+		return replaceValue(doc, path[:len(path)-1], items), nil
+	}
+}
+
+// replaceValue sets the existing location path of doc to value and returns
+// the updated document. Arrays have to be written back to their parent as
+// inserting and removing items creates new slices.
+func replaceValue(doc interface{}, path []string, value interface{}) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+	parent, _ := getValue(doc, path[:len(path)-1])
+	token := path[len(path)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[token] = value
+	case []interface{}:
+		index, _ := strconv.Atoi(token)
+		node[index] = value
+	}
+	return doc
+}
+
+// arrayIndex parses an array index token, which must not exceed max
+func arrayIndex(token string, max int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (len(token) > 1 && token[0] == '0') || token[0] == '+' {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if index > max {
+		return 0, fmt.Errorf("array index %d is out of bounds", index)
+	}
+	return index, nil
+}
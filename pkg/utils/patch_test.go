@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// assertJSONEqual fails unless got and want hold equal JSON values
+func assertJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("result %s is not JSON: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("want %s is not JSON: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"replace member", `{"title":"a","completed":false}`, `{"completed":true}`, `{"title":"a","completed":true}`},
+		{"null removes member", `{"title":"a","description":"d"}`, `{"description":null}`, `{"title":"a"}`},
+		{"null of missing member", `{"title":"a"}`, `{"description":null}`, `{"title":"a"}`},
+		{"nested objects merge", `{"a":{"b":1,"c":2}}`, `{"a":{"b":null,"d":3}}`, `{"a":{"c":2,"d":3}}`},
+		{"arrays are replaced", `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+		{"object replaces scalar", `{"a":"x"}`, `{"a":{"b":null,"c":1}}`, `{"a":{"c":1}}`},
+		{"empty patch", `{"title":"a"}`, `{}`, `{"title":"a"}`},
+		// RFC 7396: a patch that is not an object replaces the whole target
+		{"non-object patch replaces document", `{"title":"a"}`, `"x"`, `"x"`},
+		{"array patch replaces document", `{"title":"a"}`, `[1]`, `[1]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyMergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("ApplyMergePatch() error = %v", err)
+			}
+			assertJSONEqual(t, got, tt.want)
+		})
+	}
+}
+
+func TestApplyMergePatchInvalid(t *testing.T) {
+	_, err := ApplyMergePatch([]byte(`{}`), []byte(`{"a":`))
+	if !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("ApplyMergePatch() error = %v, want ErrInvalidPatch", err)
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	doc := `{"title":"a","tags":["x","y"],"nested":{"k":1},"a/b":1,"m~n":2}`
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{"add member", `[{"op":"add","path":"/description","value":"d"}]`,
+			`{"title":"a","description":"d","tags":["x","y"],"nested":{"k":1},"a/b":1,"m~n":2}`},
+		{"add replaces member", `[{"op":"add","path":"/title","value":"b"}]`,
+			`{"title":"b","tags":["x","y"],"nested":{"k":1},"a/b":1,"m~n":2}`},
+		{"add null value", `[{"op":"add","path":"/title","value":null}]`,
+			`{"title":null,"tags":["x","y"],"nested":{"k":1},"a/b":1,"m~n":2}`},
+		{"insert into array", `[{"op":"add","path":"/tags/1","value":"z"}]`,
+			`{"title":"a","tags":["x","z","y"],"nested":{"k":1},"a/b":1,"m~n":2}`},
+		{"append with - index", `[{"op":"add","path":"/tags/-","value":"z"}]`,
+			`{"title":"a","tags":["x","y","z"],"nested":{"k":1},"a/b":1,"m~n":2}`},
+		{"remove member", `[{"op":"remove","path":"/nested"}]`,
+			`{"title":"a","tags":["x","y"],"a/b":1,"m~n":2}`},
+		{"remove array item", `[{"op":"remove","path":"/tags/0"}]`,
+			`{"title":"a","tags":["y"],"nested":{"k":1},"a/b":1,"m~n":2}`},
+		{"replace nested", `[{"op":"replace","path":"/nested/k","value":2}]`,
+			`{"title":"a","tags":["x","y"],"nested":{"k":2},"a/b":1,"m~n":2}`},
+		{"replace whole document", `[{"op":"replace","path":"","value":{"title":"b"}}]`, `{"title":"b"}`},
+		{"move", `[{"op":"move","from":"/title","path":"/name"}]`,
+			`{"name":"a","tags":["x","y"],"nested":{"k":1},"a/b":1,"m~n":2}`},
+		{"move within array", `[{"op":"move","from":"/tags/0","path":"/tags/1"}]`,
+			`{"title":"a","tags":["y","x"],"nested":{"k":1},"a/b":1,"m~n":2}`},
+		{"copy", `[{"op":"copy","from":"/nested","path":"/copy"},{"op":"replace","path":"/copy/k","value":5}]`,
+			`{"title":"a","tags":["x","y"],"nested":{"k":1},"copy":{"k":5},"a/b":1,"m~n":2}`},
+		{"~1 escapes slash", `[{"op":"replace","path":"/a~1b","value":3}]`,
+			`{"title":"a","tags":["x","y"],"nested":{"k":1},"a/b":3,"m~n":2}`},
+		{"~0 escapes tilde", `[{"op":"remove","path":"/m~0n"}]`,
+			`{"title":"a","tags":["x","y"],"nested":{"k":1},"a/b":1}`},
+		{"passing tests", `[{"op":"test","path":"/title","value":"a"},{"op":"test","path":"/nested","value":{"k":1}},{"op":"test","path":"/tags/1","value":"y"}]`,
+			doc},
+		{"empty patch", `[]`, doc},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyJSONPatch([]byte(doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("ApplyJSONPatch() error = %v", err)
+			}
+			assertJSONEqual(t, got, tt.want)
+		})
+	}
+}
+
+func TestApplyJSONPatchErrors(t *testing.T) {
+	doc := `{"title":"a","tags":["x"],"completed":false}`
+	tests := []struct {
+		name  string
+		patch string
+		want  error
+	}{
+		{"failed test", `[{"op":"test","path":"/title","value":"b"}]`, ErrPatchConflict},
+		{"test does not convert types", `[{"op":"test","path":"/completed","value":0}]`, ErrPatchConflict},
+		{"failed test after other operations", `[{"op":"replace","path":"/title","value":"b"},{"op":"test","path":"/title","value":"a"}]`, ErrPatchConflict},
+		{"remove missing member", `[{"op":"remove","path":"/description"}]`, ErrPatchConflict},
+		{"replace missing member", `[{"op":"replace","path":"/description","value":"d"}]`, ErrPatchConflict},
+		{"add below missing member", `[{"op":"add","path":"/a/b","value":1}]`, ErrPatchConflict},
+		{"array index out of bounds", `[{"op":"add","path":"/tags/2","value":"z"}]`, ErrPatchConflict},
+		{"- index does not address an item", `[{"op":"test","path":"/tags/-","value":"x"}]`, ErrPatchConflict},
+		{"leading zero index", `[{"op":"remove","path":"/tags/00"}]`, ErrPatchConflict},
+		{"not an array", `{"op":"remove","path":"/title"}`, ErrInvalidPatch},
+		{"unknown op", `[{"op":"merge","path":"/title"}]`, ErrInvalidPatch},
+		{"missing op", `[{"path":"/title"}]`, ErrInvalidPatch},
+		{"missing value", `[{"op":"add","path":"/title"}]`, ErrInvalidPatch},
+		{"missing path", `[{"op":"remove"}]`, ErrInvalidPatch},
+		{"pointer without slash", `[{"op":"remove","path":"title"}]`, ErrInvalidPatch},
+		{"missing from", `[{"op":"move","path":"/title"}]`, ErrInvalidPatch},
+		{"move into itself", `[{"op":"move","from":"/tags","path":"/tags/0"}]`, ErrInvalidPatch},
+		{"remove document", `[{"op":"remove","path":""}]`, ErrInvalidPatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyJSONPatch([]byte(doc), []byte(tt.patch))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("ApplyJSONPatch() = %s, %v, want error %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyJSONPatchIsAtomic(t *testing.T) {
+	doc := []byte(`{"title":"a","tags":["x"]}`)
+	original := string(doc)
+	_, err := ApplyJSONPatch(doc, []byte(`[{"op":"add","path":"/tags/-","value":"y"},{"op":"test","path":"/title","value":"b"}]`))
+	if !errors.Is(err, ErrPatchConflict) {
+		t.Fatalf("ApplyJSONPatch() error = %v, want ErrPatchConflict", err)
+	}
+	if string(doc) != original {
+		t.Errorf("document changed to %s", doc)
+	}
+}
+
+func TestIsJSONObject(t *testing.T) {
+	for data, want := range map[string]bool{
+		`{}`:         true,
+		` {"a":1} `:  true,
+		`"x"`:        false,
+		`[{"a":1}]`:  false,
+		`null`:       false,
+		`1`:          false,
+		`{"a":`:      false,
+		`{"a":1} {}`: false,
+	} {
+		if got := IsJSONObject([]byte(data)); got != want {
+			t.Errorf("IsJSONObject(%s) = %v, want %v", data, got, want)
+		}
+	}
+}
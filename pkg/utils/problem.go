@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MIMEApplicationProblemJSON is the media type of RFC 9457 problem details
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// ProblemTypeBaseURL prefixes the type URI of every documented problem type,
+// the fragment names its section in docs/PROBLEMS.md
+const ProblemTypeBaseURL = "https://github.com/wato787/echo-todo/blob/main/docs/PROBLEMS.md#"
+
+// Problem is an RFC 9457 problem details error response
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Instance is the id of the failed request in the server logs
+	Instance string `json:"instance,omitempty"`
+	// Errors lists the invalid fields of a rejected request
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// problemTypes names the documented problem type of each status code,
+// other codes use about:blank
+var problemTypes = map[int]string{
+	http.StatusBadRequest:            "validation-error",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not-found",
+	http.StatusConflict:              "conflict",
+	http.StatusPreconditionFailed:    "precondition-failed",
+	http.StatusRequestEntityTooLarge: "request-too-large",
+	http.StatusInternalServerError:   "internal-error",
+	http.StatusServiceUnavailable:    "unavailable",
+}
+
+// NewProblem returns the problem details for an error response
+func NewProblem(code int, detail, requestID string, fields []FieldError) Problem {
+	problemType := "about:blank"
+	if name, ok := problemTypes[code]; ok {
+		problemType = ProblemTypeBaseURL + name
+	}
+	return Problem{
+		Type:     problemType,
+		Title:    http.StatusText(code),
+		Status:   code,
+		Detail:   detail,
+		Instance: requestID,
+		Errors:   fields,
+	}
+}
+
+// PrefersProblemJSON reports whether the Accept header of r asks for
+// application/problem+json at least as much as for application/json. The
+// problem type must be listed explicitly, wildcards keep the legacy format.
+func PrefersProblemJSON(r *http.Request) bool {
+	problemQ, jsonQ, wildcardQ := -1.0, -1.0, -1.0
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if value, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(value, 64); err != nil {
+					continue
+				}
+			}
+			switch mediaType {
+			case MIMEApplicationProblemJSON:
+				problemQ = max(problemQ, q)
+			case "application/json":
+				jsonQ = max(jsonQ, q)
+			case "application/*", "*/*":
+				wildcardQ = max(wildcardQ, q)
+			}
+		}
+	}
+	if jsonQ < 0 {
+		jsonQ = wildcardQ
+	}
+	return problemQ > 0 && problemQ >= jsonQ
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPrefersProblemJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept []string
+		want   bool
+	}{
+		{"no accept", nil, false},
+		{"json", []string{"application/json"}, false},
+		{"problem json", []string{"application/problem+json"}, true},
+		{"problem json and json", []string{"application/problem+json, application/json"}, true},
+		{"json and problem json", []string{"application/json, application/problem+json"}, true},
+		{"json preferred", []string{"application/problem+json;q=0.5, application/json"}, false},
+		{"problem json preferred", []string{"application/problem+json, application/json;q=0.9"}, true},
+		{"equal q", []string{"application/json;q=0.5, application/problem+json;q=0.5"}, true},
+		{"q zero", []string{"application/problem+json;q=0"}, false},
+		{"uppercase", []string{"Application/Problem+JSON"}, true},
+		{"parameters", []string{"application/problem+json; charset=utf-8"}, true},
+		{"separate headers", []string{"application/json;q=0.1", "application/problem+json"}, true},
+		// Wildcards alone keep the legacy format
+		{"any", []string{"*/*"}, false},
+		{"application wildcard", []string{"application/*"}, false},
+		{"problem json and any", []string{"application/problem+json, */*"}, true},
+		{"any preferred", []string{"application/problem+json;q=0.5, */*"}, false},
+		{"wildcard without json", []string{"application/problem+json;q=0.5, */*;q=0.1"}, true},
+		// json outranks a wildcard matching it too
+		{"json below wildcard", []string{"application/problem+json;q=0.5, application/json;q=0.1, */*"}, true},
+		{"invalid q", []string{"application/problem+json;q=high"}, false},
+		{"invalid media range", []string{"application/problem+json;;;=, application/json"}, false},
+		{"text", []string{"text/html"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, accept := range tt.accept {
+				r.Header.Add("Accept", accept)
+			}
+			if got := PrefersProblemJSON(r); got != tt.want {
+				t.Errorf("PrefersProblemJSON(%q) = %t, want %t", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
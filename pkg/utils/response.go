@@ -4,13 +4,18 @@ import (
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+
+	"echo-todo/internal/requestid"
 )
 
 type Response struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success    bool        `json:"success"`
+	Message    string      `json:"message,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	// RequestID identifies the failed request in the server logs
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // SuccessResponse returns a success response
@@ -22,11 +27,36 @@ func SuccessResponse(c echo.Context, code int, message string, data interface{})
 	})
 }
 
+// PaginatedResponse returns a success response for one page of a list
+func PaginatedResponse(c echo.Context, code int, message string, data interface{}, nextCursor string) error {
+	return c.JSON(code, Response{
+		Success:    true,
+		Message:    message,
+		Data:       data,
+		NextCursor: nextCursor,
+	})
+}
+
 // ErrorResponse returns an error response
 func ErrorResponse(c echo.Context, code int, message string) error {
+	return FieldErrorResponse(c, code, message, nil)
+}
+
+// FieldErrorResponse returns an error response listing the invalid fields of
+// the request. Clients preferring application/problem+json get RFC 9457
+// problem details, others the Response format.
+func FieldErrorResponse(c echo.Context, code int, message string, fields []FieldError) error {
+	requestID := requestid.FromContext(c.Request().Context())
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	if PrefersProblemJSON(c.Request()) {
+		c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+		return c.JSON(code, NewProblem(code, message, requestID, fields))
+	}
+
 	return c.JSON(code, Response{
-		Success: false,
-		Error:   message,
+		Success:   false,
+		Error:     message,
+		RequestID: requestID,
 	})
 }
 
@@ -35,6 +65,16 @@ func ValidationErrorResponse(c echo.Context, message string) error {
 	return ErrorResponse(c, http.StatusBadRequest, message)
 }
 
+// UnauthorizedResponse returns an unauthorized response
+func UnauthorizedResponse(c echo.Context, message string) error {
+	return ErrorResponse(c, http.StatusUnauthorized, message)
+}
+
+// ForbiddenResponse returns a forbidden response
+func ForbiddenResponse(c echo.Context, message string) error {
+	return ErrorResponse(c, http.StatusForbidden, message)
+}
+
 // NotFoundResponse returns a not found response
 func NotFoundResponse(c echo.Context, message string) error {
 	return ErrorResponse(c, http.StatusNotFound, message)
@@ -43,4 +83,4 @@ func NotFoundResponse(c echo.Context, message string) error {
 // InternalErrorResponse returns an internal server error response
 func InternalErrorResponse(c echo.Context, message string) error {
 	return ErrorResponse(c, http.StatusInternalServerError, message)
-}
\ No newline at end of file
+}
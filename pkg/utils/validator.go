@@ -2,42 +2,249 @@ package utils
 
 import (
 	"errors"
+	"fmt"
+	"reflect"
 	"strings"
+	"unicode"
 
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/ja"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	jaTranslations "github.com/go-playground/validator/v10/translations/ja"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/text/language"
 )
 
-var validate *validator.Validate
+var (
+	validate *validator.Validate
+	// translators holds the languages validation messages are available in,
+	// English is the fallback
+	translators *ut.UniversalTranslator
+)
+
+// Keys of the messages added to the validator translations
+const (
+	// invalidKey translates the message of rules without a translation
+	invalidKey = "invalid"
+	// Messages of the request body checks of JSONBinder
+	malformedBodyKey = "malformed_body"
+	invalidUTF8Key   = "invalid_utf8"
+	bodyTooLargeKey  = "body_too_large"
+	unknownFieldKey  = "unknown_field"
+	fieldTypeKey     = "field_type"
+)
+
+// messages holds the translations of the custom rules and keys, by locale
+var messages = map[string]map[string]string{
+	"en": {
+		invalidKey:       "{0} is invalid",
+		"singleline":     "{0} must not contain control characters, invisible formatting characters or line breaks",
+		"multiline":      "{0} must not contain control characters other than line breaks and tabs",
+		malformedBodyKey: "request body is not valid JSON",
+		invalidUTF8Key:   "request body is not valid UTF-8",
+		bodyTooLargeKey:  "request body must not exceed {0} bytes",
+		unknownFieldKey:  "{0} is not a known field",
+		fieldTypeKey:     "{0} must be a {1}",
+	},
+	"ja": {
+		invalidKey:       "{0}が正しくありません",
+		"singleline":     "{0}に制御文字、不可視の書式文字や改行を含めることはできません",
+		"multiline":      "{0}に改行とタブ以外の制御文字を含めることはできません",
+		malformedBodyKey: "リクエストボディが正しいJSONではありません",
+		invalidUTF8Key:   "リクエストボディが正しいUTF-8ではありません",
+		bodyTooLargeKey:  "リクエストボディは{0}バイト以下である必要があります",
+		unknownFieldKey:  "{0}は不明なフィールドです",
+		fieldTypeKey:     "{0}は{1}である必要があります",
+	},
+}
+
+// Validation aliases holding the configurable limits of todo text fields
+const (
+	TodoTitleRules       = "todo_title"
+	TodoDescriptionRules = "todo_description"
+)
+
+// Default lengths of todo text fields, in characters
+const (
+	DefaultTodoTitleMaxLength       = 200
+	DefaultTodoDescriptionMaxLength = 2000
+)
 
 func init() {
 	validate = validator.New()
+	// Report fields by the name clients send them with
+	validate.RegisterTagNameFunc(jsonFieldName)
+	mustRegisterValidation("singleline", func(fl validator.FieldLevel) bool {
+		return !strings.ContainsFunc(fl.Field().String(), func(r rune) bool {
+			// Format characters such as bidi overrides and zero width spaces
+			// hide or reorder text, U+2028 and U+2029 break lines
+			return unicode.IsControl(r) || unicode.Is(unicode.Cf, r) || r == '\u2028' || r == '\u2029'
+		})
+	})
+	mustRegisterValidation("multiline", func(fl validator.FieldLevel) bool {
+		return !strings.ContainsFunc(fl.Field().String(), func(r rune) bool {
+			return unicode.IsControl(r) && r != '\n' && r != '\t'
+		})
+	})
+	SetTodoTextLimits(DefaultTodoTitleMaxLength, DefaultTodoDescriptionMaxLength)
+
+	english := en.New()
+	translators = ut.New(english, english, ja.New())
+	registerTranslations("en", enTranslations.RegisterDefaultTranslations)
+	registerTranslations("ja", jaTranslations.RegisterDefaultTranslations)
+}
+
+func mustRegisterValidation(tag string, fn validator.Func) {
+	if err := validate.RegisterValidation(tag, fn); err != nil {
+		panic(err)
+	}
+}
+
+// SetTodoTextLimits sets the maximum length in characters of todo titles
+// and descriptions. It must be called before requests are validated.
+func SetTodoTextLimits(titleMaxLength, descriptionMaxLength int) {
+	validate.RegisterAlias(TodoTitleRules, fmt.Sprintf("max=%d,singleline", titleMaxLength))
+	validate.RegisterAlias(TodoDescriptionRules, fmt.Sprintf("max=%d,multiline", descriptionMaxLength))
+}
+
+// registerTranslations adds the validator messages of locale and the
+// messages of the custom rules and keys
+func registerTranslations(locale string, register func(*validator.Validate, ut.Translator) error) {
+	trans, _ := translators.GetTranslator(locale)
+	if err := register(validate, trans); err != nil {
+		panic(err)
+	}
+	for key, text := range messages[locale] {
+		if err := trans.Add(key, text, false); err != nil {
+			panic(err)
+		}
+	}
+	for _, tag := range []string{"singleline", "multiline"} {
+		err := validate.RegisterTranslation(tag, trans,
+			func(ut.Translator) error { return nil },
+			func(trans ut.Translator, fe validator.FieldError) string {
+				message, _ := trans.T(fe.ActualTag(), fe.Field())
+				return message
+			})
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// jsonFieldName returns the JSON name of a struct field, its Go name if it
+// has no json tag
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	default:
+		return name
+	}
 }
 
-// ValidateStruct validates a struct using validator tags
-func ValidateStruct(s interface{}) error {
+// FieldError is a validation failure of one request field
+type FieldError struct {
+	// Field is the JSON name of the field, dotted for nested fields
+	Field string `json:"field"`
+	// Rule is the validation rule that failed, e.g. required or max
+	Rule string `json:"rule"`
+	// Param is the parameter of the rule, e.g. 100 for max=100
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors lists every invalid field of a request
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, e := range v {
+		messages[i] = e.Message
+	}
+	return strings.Join(messages, ", ")
+}
+
+// ValidateStruct validates a struct using validator tags. Invalid fields are
+// returned as ValidationErrors with messages in the first of languages that
+// is supported, English by default.
+func ValidateStruct(s interface{}, languages ...string) error {
 	err := validate.Struct(s)
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return err
+	}
+
+	trans := translator(languages)
+	validationErrors := make(ValidationErrors, 0, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		validationErrors = append(validationErrors, FieldError{
+			Field:   fieldPath(fe),
+			Rule:    fe.ActualTag(),
+			Param:   fe.Param(),
+			Message: translate(fe, trans),
+		})
+	}
+	return validationErrors
+}
+
+// ValidateRequest validates s with messages in the language asked for by the
+// Accept-Language header of the request
+func ValidateRequest(c echo.Context, s interface{}) error {
+	return ValidateStruct(s, acceptedLanguages(c.Request().Header.Get("Accept-Language"))...)
+}
+
+// acceptedLanguages returns the languages of an Accept-Language header, most
+// preferred first. Regional tags are followed by their base language, e.g.
+// ja-JP by ja.
+func acceptedLanguages(header string) []string {
+	tags, weights, err := language.ParseAcceptLanguage(header)
 	if err != nil {
-		var validationErrors []string
-		for _, err := range err.(validator.ValidationErrors) {
-			validationErrors = append(validationErrors, formatValidationError(err))
+		return nil
+	}
+	var languages []string
+	for i, tag := range tags {
+		if weights[i] <= 0 {
+			continue
 		}
-		return errors.New(strings.Join(validationErrors, ", "))
-	}
-	return nil
-}
-
-func formatValidationError(err validator.FieldError) string {
-	field := strings.ToLower(err.Field())
-	switch err.Tag() {
-	case "required":
-		return field + " is required"
-	case "min":
-		return field + " must be at least " + err.Param() + " characters long"
-	case "max":
-		return field + " must be at most " + err.Param() + " characters long"
-	case "email":
-		return field + " must be a valid email address"
-	default:
-		return field + " is invalid"
+		base, _ := tag.Base()
+		languages = append(languages, strings.ReplaceAll(tag.String(), "-", "_"), base.String())
 	}
-}
\ No newline at end of file
+	return languages
+}
+
+// requestTranslator returns the translator of the language asked for by
+// the Accept-Language header of the request
+func requestTranslator(c echo.Context) ut.Translator {
+	return translator(acceptedLanguages(c.Request().Header.Get("Accept-Language")))
+}
+
+// translator returns the translator of the first supported language
+func translator(languages []string) ut.Translator {
+	trans, _ := translators.FindTranslator(languages...)
+	return trans
+}
+
+// translate returns the message of fe in the language of trans
+func translate(fe validator.FieldError, trans ut.Translator) string {
+	message := fe.Translate(trans)
+	// Rules without a translation fall back to the raw validator error
+	if message == fe.Error() {
+		message, _ = trans.T(invalidKey, fe.Field())
+	}
+	return message
+}
+
+// fieldPath returns the dotted JSON path of fe without the struct name
+func fieldPath(fe validator.FieldError) string {
+	_, path, found := strings.Cut(fe.Namespace(), ".")
+	if !found {
+		return fe.Field()
+	}
+	return path
+}
@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// translatedRequest has a field failing each rule with a message
+type translatedRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Title       string `json:"title" validate:"max=3"`
+	Line        string `json:"line" validate:"singleline"`
+	Description string `json:"description" validate:"multiline"`
+	// uuid4_rfc4122 has no translation and gets the invalid message
+	Code string `json:"code" validate:"uuid4_rfc4122"`
+}
+
+// invalidRequest fails every rule of translatedRequest
+var invalidRequest = translatedRequest{Title: "abcd", Line: "a\nb", Description: "a\x00b", Code: "x"}
+
+func TestValidateStructTranslations(t *testing.T) {
+	tests := []struct {
+		language string
+		want     []string
+	}{
+		{"en", []string{
+			"name is a required field",
+			"title must be a maximum of 3 characters in length",
+			"line must not contain control characters, invisible formatting characters or line breaks",
+			"description must not contain control characters other than line breaks and tabs",
+			"code is invalid",
+		}},
+		{"ja", []string{
+			"nameは必須フィールドです",
+			"titleの長さは最大でも3文字でなければなりません",
+			"lineに制御文字、不可視の書式文字や改行を含めることはできません",
+			"descriptionに改行とタブ以外の制御文字を含めることはできません",
+			"codeが正しくありません",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			var fields ValidationErrors
+			if err := ValidateStruct(invalidRequest, tt.language); !errors.As(err, &fields) {
+				t.Fatalf("ValidateStruct() error = %v, want ValidationErrors", err)
+			}
+			var messages []string
+			for _, field := range fields {
+				messages = append(messages, field.Message)
+			}
+			if !reflect.DeepEqual(messages, tt.want) {
+				t.Errorf("messages = %q, want %q", messages, tt.want)
+			}
+			if fields[1].Rule != "max" || fields[1].Param != "3" || fields[1].Field != "title" {
+				t.Errorf("max error = %+v", fields[1])
+			}
+		})
+	}
+}
+
+// TestValidateRequestLanguage checks the message language follows the
+// Accept-Language header, falling back to English
+func TestValidateRequestLanguage(t *testing.T) {
+	const english, japanese = "name is a required field", "nameは必須フィールドです"
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"none", "", english},
+		{"english", "en", english},
+		{"japanese", "ja", japanese},
+		{"regional", "ja-JP", japanese},
+		{"first supported", "fr-FR, ja;q=0.8, en;q=0.5", japanese},
+		{"by weight", "en;q=0.5, ja", japanese},
+		{"refused", "ja;q=0, en", english},
+		{"unsupported", "fr, de", english},
+		{"malformed", "ja;q=x;;", english},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Accept-Language", tt.header)
+			}
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+			var fields ValidationErrors
+			if err := ValidateRequest(c, translatedRequest{Title: "a"}); !errors.As(err, &fields) {
+				t.Fatalf("ValidateRequest() error = %v, want ValidationErrors", err)
+			}
+			if fields[0].Message != tt.want {
+				t.Errorf("message = %q, want %q", fields[0].Message, tt.want)
+			}
+		})
+	}
+}
+
+// TestBodyMessageTranslations checks the request body errors of the binder
+// are translated too
+func TestBodyMessageTranslations(t *testing.T) {
+	var target struct {
+		Completed bool `json:"completed"`
+	}
+	tests := []struct {
+		name string
+		body string
+		en   string
+		ja   string
+	}{
+		{"malformed body", `{"completed":`, "request body is not valid JSON", "リクエストボディが正しいJSONではありません"},
+		{"field type", `{"completed":"yes"}`, "completed must be a boolean", "completedはbooleanである必要があります"},
+		{"unknown field", `{"done":true}`, "done is not a known field", "doneは不明なフィールドです"},
+		{"invalid utf8", "{\"completed\":\"\xff\"}", "request body is not valid UTF-8", "リクエストボディが正しいUTF-8ではありません"},
+	}
+	for _, tt := range tests {
+		for language, want := range map[string]string{"en": tt.en, "ja": tt.ja} {
+			t.Run(tt.name+"/"+language, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+				req.Header.Set("Accept-Language", language)
+				c := echo.New().NewContext(req, httptest.NewRecorder())
+				body, err := ReadJSONBody(c)
+				if err == nil {
+					err = DecodeJSON(c, body, &target, true)
+				}
+				var he *echo.HTTPError
+				if !errors.As(err, &he) || he.Message != want {
+					t.Errorf("error = %v, want %q", err, want)
+				}
+			})
+		}
+	}
+}
+
+func TestTextRules(t *testing.T) {
+	type text struct {
+		Line        string `validate:"singleline"`
+		Description string `validate:"multiline"`
+	}
+	tests := []struct {
+		name      string
+		value     string
+		line      bool
+		multiline bool
+	}{
+		{"plain", "buy milk ミルク 🥛", true, true},
+		{"space separators", "a\u3000b\u00a0c", true, true},
+		{"line feed", "a\nb", false, true},
+		{"tab", "a\tb", false, true},
+		{"carriage return", "a\rb", false, false},
+		{"nul", "a\x00b", false, false},
+		{"line separator", "a\u2028b", false, true},
+		{"paragraph separator", "a\u2029b", false, true},
+		{"zero width space", "a\u200bb", false, true},
+		{"bidi override", "a\u202eb", false, true},
+		{"byte order mark", "\ufeffa", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, check := range []struct {
+				rule  string
+				value text
+				want  bool
+			}{
+				{"singleline", text{Line: tt.value}, tt.line},
+				{"multiline", text{Description: tt.value}, tt.multiline},
+			} {
+				if err := ValidateStruct(check.value); (err == nil) != check.want {
+					t.Errorf("%s(%q) error = %v, want valid %t", check.rule, tt.value, err, check.want)
+				}
+			}
+		})
+	}
+}